@@ -0,0 +1,63 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides current time for expiry checks and timestamps,
+// it's supposed to be replaced in tests (see storetest.FakeClock)
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+// SystemClock return Clock reading system time, it's used by default
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clockedStore check expiry of sessions returned by Store at the service clock,
+// so the service doesn't return sessions expired in application time
+// when Store keeps its own time (e.g. database server time)
+type clockedStore struct {
+	Store
+	clock Clock
+}
+
+func (st *clockedStore) Create(ctx context.Context, s *Session) (*Session, error) {
+	return st.check(st.Store.Create(ctx, s))
+}
+
+func (st *clockedStore) Load(ctx context.Context, sid string) (*Session, error) {
+	return st.check(st.Store.Load(ctx, sid))
+}
+
+func (st *clockedStore) Peek(ctx context.Context, sid string) (*Session, error) {
+	return st.check(st.Store.Peek(ctx, sid))
+}
+
+func (st *clockedStore) Update(ctx context.Context, s *Session) (*Session, error) {
+	return st.check(st.Store.Update(ctx, s))
+}
+
+func (st *clockedStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*Session)) (*Session, error) {
+	return st.check(st.Store.Regenerate(ctx, sid, newSID, grace, modify))
+}
+
+// check return ErrSessionExpired or ErrSessionInvalidated if s isn't live at the clock time
+func (st *clockedStore) check(s *Session, err error) (*Session, error) {
+	if err != nil {
+		return nil, err
+	}
+	err = s.CheckExpiredAt(st.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
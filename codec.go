@@ -0,0 +1,12 @@
+package session
+
+// Codec encode and decode session attributes,
+// it's supposed to be used by stores keeping attributes as bytes (files, Redis, SQL),
+// so attributes are read back the same way from any store.
+//
+// Implementations are in package github.com/asstart/go-session/codec,
+// they restore attribute types registered with RegisterType.
+type Codec interface {
+	Marshal(data map[string]interface{}) ([]byte, error)
+	Unmarshal(b []byte) (map[string]interface{}, error)
+}
@@ -0,0 +1,60 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/asstart/go-session"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+type bsonDocument struct {
+	Data  map[string]interface{} `bson:"data"`
+	Types map[string]string      `bson:"types,omitempty"`
+}
+
+type bsonCodec struct {
+	registry *bsoncodec.Registry
+}
+
+/*
+NewBSON Create session.Codec encoding attributes as BSON
+
+Types of attributes registered with session.RegisterType are kept next to the data
+and restored on decoding, other values are decoded the same way as by mongo store:
+dates as time.Time, documents as map[string]interface{} and arrays as []interface{}.
+*/
+func NewBSON() session.Codec {
+	rb := bsoncodec.NewRegistryBuilder()
+
+	bsoncodec.DefaultValueEncoders{}.RegisterDefaultEncoders(rb)
+	bsoncodec.DefaultValueDecoders{}.RegisterDefaultDecoders(rb)
+
+	rb.RegisterTypeMapEntry(bsontype.DateTime, reflect.TypeOf(time.Time{}))
+	rb.RegisterTypeMapEntry(bson.TypeArray, reflect.TypeOf([]interface{}{}))
+	rb.RegisterTypeMapEntry(bson.TypeEmbeddedDocument, reflect.TypeOf(map[string]interface{}{}))
+
+	return bsonCodec{registry: rb.Build()}
+}
+
+func (c bsonCodec) Marshal(data map[string]interface{}) ([]byte, error) {
+	b, err := bson.MarshalWithRegistry(c.registry, bsonDocument{Data: data, Types: session.DataTypes(data)})
+	if err != nil {
+		return nil, fmt.Errorf("session.codec.BSON.Marshal() error: %w", err)
+	}
+	return b, nil
+}
+
+func (c bsonCodec) Unmarshal(b []byte) (map[string]interface{}, error) {
+	var doc bsonDocument
+	err := bson.UnmarshalWithRegistry(c.registry, b, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("session.codec.BSON.Unmarshal() error: %w", err)
+	}
+
+	session.RestoreAttributeTypes(doc.Data, doc.Types)
+	return doc.Data, nil
+}
@@ -0,0 +1,113 @@
+package codec_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCart struct {
+	Items []string
+	Total float64
+}
+
+func init() {
+	session.RegisterType[testCart]("codec_test.testCart")
+}
+
+func testCodecs() []struct {
+	name  string
+	codec session.Codec
+} {
+	return []struct {
+		name  string
+		codec session.Codec
+	}{
+		{"json", codec.NewJSON()},
+		{"gob", codec.NewGob()},
+		{"bson", codec.NewBSON()},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2022, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	data := map[string]interface{}{
+		"int":       1,
+		"int8":      int8(-8),
+		"uint16":    uint16(16),
+		"int64":     int64(1) << 40,
+		"float32":   float32(1.5),
+		"float64":   2.5,
+		"string":    "v",
+		"bool":      true,
+		"time":      now,
+		"[]int":     []int{1, 2},
+		"[]float64": []float64{2.5},
+		"[]string":  []string{"a", "b"},
+		"[]time":    []time.Time{now},
+		"map":       map[string]string{"k": "v"},
+		"cart":      testCart{Items: []string{"a"}, Total: 1.5},
+		"nested":    map[string]interface{}{"[]string": []string{"c"}, "int": 2},
+	}
+
+	for _, tc := range testCodecs() {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.codec.Marshal(data)
+			require.Nil(t, err)
+
+			got, err := tc.codec.Unmarshal(b)
+			require.Nil(t, err)
+			assert.Equal(t, data, got)
+		})
+	}
+}
+
+func TestRoundTripUntyped(t *testing.T) {
+	data := map[string]interface{}{
+		"[]interface": []interface{}{"a", int64(1), 1.5, map[string]interface{}{"k": "v"}},
+		"nil":         nil,
+	}
+
+	for _, tc := range testCodecs() {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.codec.Marshal(data)
+			require.Nil(t, err)
+
+			got, err := tc.codec.Unmarshal(b)
+			require.Nil(t, err)
+			assert.Equal(t, data, got)
+		})
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	for _, tc := range testCodecs() {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.codec.Marshal(map[string]interface{}{})
+			require.Nil(t, err)
+
+			got, err := tc.codec.Unmarshal(b)
+			require.Nil(t, err)
+			assert.Empty(t, got)
+		})
+	}
+}
+
+func TestUnmarshalError(t *testing.T) {
+	for _, tc := range testCodecs() {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.codec.Unmarshal([]byte("garbage"))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGobUnregisteredType(t *testing.T) {
+	_, err := codec.NewGob().Marshal(map[string]interface{}{"k": struct{ Name string }{"n"}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/asstart/go-session"
+)
+
+// gobRegistered keep types already registered with gob
+var gobRegistered sync.Map
+
+type gobCodec struct{}
+
+/*
+NewGob Create session.Codec encoding attributes with encoding/gob
+
+Gob keeps concrete types of the values, but it should know them in advance,
+so types registered with session.RegisterType are registered with gob as well.
+Attributes of other types, except maps and slices of interface{}, can't be encoded.
+*/
+func NewGob() session.Codec {
+	registerGobTypes()
+	return gobCodec{}
+}
+
+func (gobCodec) Marshal(data map[string]interface{}) ([]byte, error) {
+	registerGobTypes()
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(data)
+	if err != nil {
+		return nil, fmt.Errorf("session.codec.Gob.Marshal() error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(b []byte) (map[string]interface{}, error) {
+	registerGobTypes()
+
+	var data map[string]interface{}
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data)
+	if err != nil {
+		return nil, fmt.Errorf("session.codec.Gob.Unmarshal() error: %w", err)
+	}
+	return data, nil
+}
+
+// registerGobTypes register with gob types registered with session.RegisterType since last call
+func registerGobTypes() {
+	gobRegister(reflect.TypeOf(map[string]interface{}{}))
+	gobRegister(reflect.TypeOf([]interface{}{}))
+	gobRegister(reflect.TypeOf(time.Time{}))
+	for _, t := range session.RegisteredTypes() {
+		gobRegister(t)
+	}
+}
+
+func gobRegister(t reflect.Type) {
+	if _, loaded := gobRegistered.LoadOrStore(t, true); loaded {
+		return
+	}
+
+	// gob panics if the type is already registered under another name,
+	// it's fine since the type can be encoded anyway
+	defer func() {
+		_ = recover()
+	}()
+	gob.Register(reflect.Zero(t).Interface())
+}
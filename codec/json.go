@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/asstart/go-session"
+)
+
+type jsonDocument struct {
+	Data  map[string]interface{} `json:"data"`
+	Types map[string]string      `json:"types,omitempty"`
+}
+
+type jsonCodec struct{}
+
+/*
+NewJSON Create session.Codec encoding attributes as JSON
+
+Types of attributes registered with session.RegisterType are kept next to the data
+and restored on decoding, other numbers are decoded as int64 if they're integers
+and as float64 otherwise, objects are decoded as map[string]interface{}
+and arrays as []interface{}.
+*/
+func NewJSON() session.Codec {
+	return jsonCodec{}
+}
+
+func (jsonCodec) Marshal(data map[string]interface{}) ([]byte, error) {
+	b, err := json.Marshal(jsonDocument{Data: data, Types: session.DataTypes(data)})
+	if err != nil {
+		return nil, fmt.Errorf("session.codec.JSON.Marshal() error: %w", err)
+	}
+	return b, nil
+}
+
+func (jsonCodec) Unmarshal(b []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var doc jsonDocument
+	err := d.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("session.codec.JSON.Unmarshal() error: %w", err)
+	}
+
+	if doc.Data == nil {
+		return nil, nil
+	}
+
+	data := fromJSONNumbers(doc.Data).(map[string]interface{})
+	session.RestoreAttributeTypes(data, doc.Types)
+	return data, nil
+}
+
+// fromJSONNumbers replace json.Number with int64 or float64
+func fromJSONNumbers(v interface{}) interface{} {
+	switch cv := v.(type) {
+	case json.Number:
+		if i, err := cv.Int64(); err == nil {
+			return i
+		}
+		f, _ := cv.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range cv {
+			cv[k] = fromJSONNumbers(e)
+		}
+		return cv
+	case []interface{}:
+		for i, e := range cv {
+			cv[i] = fromJSONNumbers(e)
+		}
+		return cv
+	default:
+		return v
+	}
+}
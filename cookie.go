@@ -0,0 +1,153 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var ErrNoSessionCookie = errors.New("sessionservice: session cookie not found")
+
+// CookieCodec read session id from the request cookies and write session cookie to the response,
+// it lets stores keep session id in another format or split it across several cookies.
+//
+// SID should return ErrNoSessionCookie if request doesn't have session cookie.
+// SetCookie is called with cookie built by Session.Cookie or ExpiredCookie,
+// r is the request the response is written for.
+type CookieCodec interface {
+	SID(r *http.Request, name string) (string, error)
+	SetCookie(w http.ResponseWriter, r *http.Request, c *http.Cookie)
+}
+
+type sidCookieCodec struct {
+	validate func(sid string) error
+}
+
+// DefaultCookieCodec return CookieCodec keeping session id in a single cookie,
+// session id is validated with ValidateSessionID, so it accepts only ids of the default IDGenerator,
+// signature of signed id isn't verified by the codec.
+// Use NewCookieCodec with IDGenerator.Validate for ids of other generators
+func DefaultCookieCodec() CookieCodec {
+	return NewCookieCodec(func(sid string) error {
+		return ValidateSessionID(sid)
+	})
+}
+
+// NewCookieCodec return CookieCodec keeping session id in a single cookie,
+// session id is validated with validate, e.g. IDGenerator.Validate,
+// signature of session id signed by Signer is stripped before validation
+func NewCookieCodec(validate func(sid string) error) CookieCodec {
+	return sidCookieCodec{validate: validate}
+}
+
+func (cc sidCookieCodec) SID(r *http.Request, name string) (string, error) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", ErrNoSessionCookie
+	}
+
+	sid := c.Value
+	if unsigned, _, ok := splitSignature(sid); ok {
+		sid = unsigned
+	}
+
+	err = cc.validate(sid)
+	if err != nil {
+		return "", fmt.Errorf("error reading session cookie: %w", err)
+	}
+
+	return c.Value, nil
+}
+
+func (cc sidCookieCodec) SetCookie(w http.ResponseWriter, r *http.Request, c *http.Cookie) {
+	http.SetCookie(w, c)
+}
+
+// Cookie return cookie carrying session id built from Session.Opts
+//
+// Secure is always set for SameSiteNoneMode,
+// since browsers reject SameSite=None cookies without it
+func (s *Session) Cookie(name string) *http.Cookie {
+	c := newCookie(name, s.Opts)
+	c.Value = s.ID
+	c.MaxAge = s.Opts.MaxAge
+	return c
+}
+
+// ExpiredCookie return cookie which removes the session cookie from a browser,
+// it's supposed to be used on logout
+func (s *Session) ExpiredCookie(name string) *http.Cookie {
+	return ExpiredCookie(name, s.Opts)
+}
+
+// ExpiredCookie return cookie which removes the session cookie from a browser
+// when the session itself isn't available
+// cc should have the same Path and Domain as the cookie being removed
+func ExpiredCookie(name string, cc CookieConf) *http.Cookie {
+	c := newCookie(name, cc)
+	c.MaxAge = -1
+	c.Expires = time.Unix(0, 0)
+	return c
+}
+
+// SIDFromRequest return session id from the cookie with provided name
+// return ErrNoSessionCookie if request doesn't have the cookie
+// and validation error if session id has wrong format.
+// Session id is validated with ValidateSessionID, if generators are passed
+// it should be valid for one of them instead (e.g. the one passed to WithIDGenerator)
+func SIDFromRequest(r *http.Request, name string, generators ...IDGenerator) (string, error) {
+	if len(generators) > 0 {
+		validate := func(sid string) error {
+			var err error
+			for _, g := range generators {
+				err = g.Validate(sid)
+				if err == nil {
+					return nil
+				}
+			}
+			return err
+		}
+		return NewCookieCodec(validate).SID(r, name)
+	}
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", ErrNoSessionCookie
+	}
+
+	err = ValidateSessionID(c.Value)
+	if err != nil {
+		return "", fmt.Errorf("error reading session cookie: %w", err)
+	}
+
+	return c.Value, nil
+}
+
+func newCookie(name string, cc CookieConf) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Path:     cc.Path,
+		Domain:   cc.Domain,
+		Secure:   cc.Secure || cc.SameSite == SameSiteNoneMode,
+		HttpOnly: cc.HTTPOnly,
+		SameSite: cc.SameSite.httpSameSite(),
+	}
+}
+
+// httpSameSite map SameSite to http.SameSite
+// unknown values are mapped to 0, so SameSite attribute isn't written
+func (ss SameSite) httpSameSite() http.SameSite {
+	switch ss {
+	case SameSiteDefaultMode:
+		return http.SameSiteDefaultMode
+	case SameSiteLaxMode:
+		return http.SameSiteLaxMode
+	case SameSiteStrictMode:
+		return http.SameSiteStrictMode
+	case SameSiteNoneMode:
+		return http.SameSiteNoneMode
+	default:
+		return 0
+	}
+}
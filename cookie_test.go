@@ -0,0 +1,115 @@
+package session_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/asstart/go-session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionCookie(t *testing.T) {
+	tt := []struct {
+		name        string
+		cc          session.CookieConf
+		expSameSite http.SameSite
+		expSecure   bool
+	}{
+		{"default mode", session.CookieConf{SameSite: session.SameSiteDefaultMode}, http.SameSiteDefaultMode, false},
+		{"lax mode", session.CookieConf{SameSite: session.SameSiteLaxMode}, http.SameSiteLaxMode, false},
+		{"strict mode", session.CookieConf{SameSite: session.SameSiteStrictMode, Secure: true}, http.SameSiteStrictMode, true},
+		{"none mode enforces secure", session.CookieConf{SameSite: session.SameSiteNoneMode}, http.SameSiteNoneMode, true},
+		{"unset mode", session.CookieConf{}, 0, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := session.Session{ID: "sid", Opts: tc.cc}
+			c := s.Cookie("name")
+			assert.Equal(t, tc.expSameSite, c.SameSite)
+			assert.Equal(t, tc.expSecure, c.Secure)
+		})
+	}
+}
+
+func TestSessionCookieAttributes(t *testing.T) {
+	s := session.Session{
+		ID: "sid",
+		Opts: session.CookieConf{
+			Path:     "/app",
+			Domain:   "example.com",
+			Secure:   true,
+			HTTPOnly: true,
+			MaxAge:   3600,
+			SameSite: session.SameSiteLaxMode,
+		},
+	}
+
+	c := s.Cookie("session")
+	assert.Equal(t, "session=sid; Path=/app; Domain=example.com; Max-Age=3600; HttpOnly; Secure; SameSite=Lax", c.String())
+}
+
+func TestExpiredCookie(t *testing.T) {
+	s := session.Session{ID: "sid", Opts: session.DefaultCookieConf()}
+
+	c := s.ExpiredCookie("name")
+	assert.Equal(t, "name", c.Name)
+	assert.Equal(t, "", c.Value)
+	assert.True(t, c.MaxAge < 0)
+	assert.Equal(t, s.Opts.Path, c.Path)
+	assert.Contains(t, c.String(), "Max-Age=0")
+}
+
+func TestSIDFromRequest(t *testing.T) {
+	validSID := "A7TF7SGM5WZRW7WMGY7BRJPQOGWGXATZWT35HXPKHRO3DU2J3L4Q"
+
+	tt := []struct {
+		name   string
+		cookie *http.Cookie
+		expSID string
+		expErr bool
+	}{
+		{"valid sid", &http.Cookie{Name: "sid", Value: validSID}, validSID, false},
+		{"no cookie", nil, "", true},
+		{"another cookie", &http.Cookie{Name: "other", Value: validSID}, "", true},
+		{"invalid sid", &http.Cookie{Name: "sid", Value: "invalid"}, "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.cookie != nil {
+				r.AddCookie(tc.cookie)
+			}
+
+			sid, err := session.SIDFromRequest(r, "sid")
+			assert.Equal(t, tc.expSID, sid)
+			assert.Equal(t, tc.expErr, err != nil)
+		})
+	}
+}
+
+func TestSIDFromRequestWithGenerators(t *testing.T) {
+	gen := session.NewPrefixedIDGenerator("sess_", session.NewBase64URLIDGenerator())
+	sid, err := gen.Generate()
+	assert.Nil(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sid", Value: sid})
+
+	_, err = session.SIDFromRequest(r, "sid")
+	assert.NotNil(t, err)
+
+	read, err := session.SIDFromRequest(r, "sid", session.NewBase32IDGenerator(), gen)
+	assert.Nil(t, err)
+	assert.Equal(t, sid, read)
+}
+
+func TestSIDFromRequestNoCookie(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	_, err := session.SIDFromRequest(r, "sid")
+	assert.True(t, errors.Is(err, session.ErrNoSessionCookie))
+}
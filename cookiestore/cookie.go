@@ -0,0 +1,106 @@
+package cookiestore
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asstart/go-session"
+)
+
+// DefaultChunkSize is size of session cookie value browsers accept together with cookie attributes
+const DefaultChunkSize = 3800
+
+// maxChunks limit number of chunk cookies read from a request
+const maxChunks = 16
+
+var errMissingChunk = errors.New("session cookie chunk is missing")
+
+type cookieCodec struct {
+	chunkSize int
+}
+
+/*
+NewCookieCodec Create session.CookieCodec for tokens issued by the store,
+it's supposed to be passed to session.WithCookieCodec.
+
+Tokens longer than chunkSize are split across cookies <name>_1, <name>_2 and so on
+and cookie <name> keeps number of chunks, chunks left from longer tokens are removed.
+chunkSize <= 0 disables chunking, the store limits token size anyway (see WithMaxSize).
+*/
+func NewCookieCodec(chunkSize int) session.CookieCodec {
+	return cookieCodec{chunkSize: chunkSize}
+}
+
+func (cc cookieCodec) SID(r *http.Request, name string) (string, error) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", session.ErrNoSessionCookie
+	}
+
+	n, err := strconv.Atoi(c.Value)
+	if err != nil {
+		return c.Value, nil
+	}
+	if n < 1 || n > maxChunks {
+		return "", fmt.Errorf("error reading session cookie: wrong number of chunks %d", n)
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		c, err := r.Cookie(chunkName(name, i))
+		if err != nil {
+			return "", fmt.Errorf("error reading session cookie: %w", errMissingChunk)
+		}
+		sb.WriteString(c.Value)
+	}
+
+	return sb.String(), nil
+}
+
+func (cc cookieCodec) SetCookie(w http.ResponseWriter, r *http.Request, c *http.Cookie) {
+	chunks := 0
+	if c.MaxAge >= 0 && cc.chunkSize > 0 && len(c.Value) > cc.chunkSize {
+		chunks = (len(c.Value) + cc.chunkSize - 1) / cc.chunkSize
+	}
+
+	if chunks == 0 {
+		http.SetCookie(w, c)
+	} else {
+		main := *c
+		main.Value = strconv.Itoa(chunks)
+		http.SetCookie(w, &main)
+
+		for i := 1; i <= chunks; i++ {
+			chunk := *c
+			chunk.Name = chunkName(c.Name, i)
+			end := i * cc.chunkSize
+			if end > len(c.Value) {
+				end = len(c.Value)
+			}
+			chunk.Value = c.Value[(i-1)*cc.chunkSize : end]
+			http.SetCookie(w, &chunk)
+		}
+	}
+
+	// remove chunks of the previous token
+	for i := chunks + 1; i <= maxChunks; i++ {
+		name := chunkName(c.Name, i)
+		if _, err := r.Cookie(name); err != nil {
+			continue
+		}
+		expired := *c
+		expired.Name = name
+		expired.Value = ""
+		expired.MaxAge = -1
+		expired.Expires = time.Unix(0, 0)
+		http.SetCookie(w, &expired)
+	}
+}
+
+func chunkName(name string, i int) string {
+	return name + "_" + strconv.Itoa(i)
+}
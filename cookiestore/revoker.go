@@ -0,0 +1,85 @@
+package cookiestore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/asstart/go-session"
+)
+
+// Revoker keeps ids of sessions invalidated before they expire,
+// cookies can't be removed from clients, so the store checks every token against Revoker.
+//
+// Ids passed to Revoker are stable ids of sessions, they aren't changed when a token is reissued,
+// so revoking the id makes all tokens issued for the session invalid.
+type Revoker interface {
+	// Revoke make session invalid since at,
+	// until is when the session expires anyway, so the record isn't needed after it
+	Revoke(ctx context.Context, id string, at, until time.Time) error
+	// RevokedAt return when session was revoked, zero time if it isn't revoked
+	RevokedAt(ctx context.Context, id string) (time.Time, error)
+}
+
+type revocation struct {
+	at    time.Time
+	until time.Time
+}
+
+type memoryRevoker struct {
+	mu      sync.Mutex
+	revoked map[string]revocation
+
+	Clock session.Clock
+}
+
+// RevokerOption configures Revoker created by NewMemoryRevoker
+type RevokerOption func(*memoryRevoker)
+
+// WithRevokerClock set clock used to remove records of expired sessions,
+// it should be the clock passed to the store with WithClock. session.SystemClock is used by default
+func WithRevokerClock(c session.Clock) RevokerOption {
+	return func(mr *memoryRevoker) {
+		mr.Clock = c
+	}
+}
+
+// NewMemoryRevoker Create Revoker keeping revoked ids in memory,
+// it's supposed to be used in single-node deployments,
+// records are removed once sessions expire
+func NewMemoryRevoker(opts ...RevokerOption) Revoker {
+	mr := &memoryRevoker{
+		revoked: make(map[string]revocation),
+		Clock:   session.SystemClock(),
+	}
+	for _, o := range opts {
+		o(mr)
+	}
+	return mr
+}
+
+func (mr *memoryRevoker) Revoke(ctx context.Context, id string, at, until time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	now := mr.Clock.Now()
+	for k, r := range mr.revoked {
+		if r.until.Before(now) {
+			delete(mr.revoked, k)
+		}
+	}
+
+	if r, ok := mr.revoked[id]; ok && r.at.Before(at) {
+		return nil
+	}
+	mr.revoked[id] = revocation{at: at, until: until}
+
+	return nil
+}
+
+func (mr *memoryRevoker) RevokedAt(ctx context.Context, id string) (time.Time, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.revoked[id].at, nil
+}
@@ -0,0 +1,37 @@
+package cookiestore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session/cookiestore"
+	"github.com/asstart/go-session/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRevokerClock(t *testing.T) {
+	ctx := context.Background()
+	clock := storetest.NewFakeClock(time.Now())
+	r := cookiestore.NewMemoryRevoker(cookiestore.WithRevokerClock(clock))
+
+	now := clock.Now()
+	require.Nil(t, r.Revoke(ctx, "expiring", now, now.Add(time.Hour)))
+	require.Nil(t, r.Revoke(ctx, "kept", now, now.Add(3*time.Hour)))
+
+	at, err := r.RevokedAt(ctx, "expiring")
+	require.Nil(t, err)
+	assert.Equal(t, now, at)
+
+	clock.Advance(2 * time.Hour)
+	require.Nil(t, r.Revoke(ctx, "other", clock.Now(), clock.Now().Add(time.Hour)))
+
+	at, err = r.RevokedAt(ctx, "expiring")
+	require.Nil(t, err)
+	assert.True(t, at.IsZero())
+
+	at, err = r.RevokedAt(ctx, "kept")
+	require.Nil(t, err)
+	assert.Equal(t, now, at)
+}
@@ -0,0 +1,221 @@
+package cookiestore
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/asstart/go-session"
+)
+
+// tokenVersion is the first byte of every token, it's authenticated as additional data
+const tokenVersion = 1
+
+const (
+	plain    = 0
+	deflated = 1
+)
+
+var errMalformedToken = errors.New("malformed token")
+
+var tokenEnc = base64.RawURLEncoding
+
+// sealedSession is everything from session.Session except Data,
+// ID is stable id of the session, it isn't changed when token is reissued
+type sealedSession struct {
+	ID             string             `json:"id"`
+	Opts           session.CookieConf `json:"opts"`
+	Anonym         bool               `json:"anonym,omitempty"`
+	Active         bool               `json:"active,omitempty"`
+	UID            string             `json:"uid,omitempty"`
+	IdleTimeout    time.Duration      `json:"idle"`
+	AbsTimeout     time.Duration      `json:"abs"`
+	LastAccessedAt time.Time          `json:"accessed"`
+	CreatedAt      time.Time          `json:"created"`
+	Version        int64              `json:"version"`
+}
+
+// sealer encrypt sessions with the first key and decrypt them with any key
+type sealer struct {
+	aeads []cipher.AEAD
+	codec session.Codec
+}
+
+func newSealer(keys [][]byte, c session.Codec) (*sealer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for i, k := range keys {
+		b, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(b)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		aeads = append(aeads, aead)
+	}
+
+	return &sealer{aeads: aeads, codec: c}, nil
+}
+
+// seal serialize, compress and encrypt s,
+// plaintext is compression flag followed by
+// uvarint length of JSON encoded sealedSession, the JSON itself and data encoded by codec
+func (sl *sealer) seal(s *session.Session) (string, error) {
+	hdr, err := json.Marshal(sealedSession{
+		ID:             s.ID,
+		Opts:           s.Opts,
+		Anonym:         s.Anonym,
+		Active:         s.Active,
+		UID:            s.UID,
+		IdleTimeout:    s.IdleTimeout,
+		AbsTimeout:     s.AbsTimeout,
+		LastAccessedAt: s.LastAccessedAt,
+		CreatedAt:      s.CreatedAt,
+		Version:        s.Version,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	data, err := sl.codec.Marshal(s.Data)
+	if err != nil {
+		return "", err
+	}
+
+	body := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(hdr)+len(data))
+	body = body[:binary.PutUvarint(body, uint64(len(hdr)))]
+	body = append(body, hdr...)
+	body = append(body, data...)
+
+	pt, err := compress(body)
+	if err != nil {
+		return "", err
+	}
+
+	aead := sl.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	token := append([]byte{tokenVersion}, nonce...)
+	token = aead.Seal(token, nonce, pt, []byte{tokenVersion})
+
+	return tokenEnc.EncodeToString(token), nil
+}
+
+// open decrypt token sealed with any of the keys and return the session with its stable id
+func (sl *sealer) open(token string) (*session.Session, error) {
+	raw, err := tokenEnc.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 || raw[0] != tokenVersion {
+		return nil, errMalformedToken
+	}
+	raw = raw[1:]
+
+	var pt []byte
+	for _, aead := range sl.aeads {
+		if len(raw) < aead.NonceSize() {
+			continue
+		}
+		pt, err = aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte{tokenVersion})
+		if err == nil {
+			break
+		}
+	}
+	if pt == nil {
+		return nil, errMalformedToken
+	}
+
+	body, err := decompress(pt)
+	if err != nil {
+		return nil, err
+	}
+
+	n, l := binary.Uvarint(body)
+	if l <= 0 || n > uint64(len(body)-l) {
+		return nil, errMalformedToken
+	}
+	body = body[l:]
+
+	var hdr sealedSession
+	err = json.Unmarshal(body[:n], &hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := sl.codec.Unmarshal(body[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &session.Session{
+		ID:             hdr.ID,
+		Data:           data,
+		Opts:           hdr.Opts,
+		Anonym:         hdr.Anonym,
+		Active:         hdr.Active,
+		UID:            hdr.UID,
+		IdleTimeout:    hdr.IdleTimeout,
+		AbsTimeout:     hdr.AbsTimeout,
+		LastAccessedAt: hdr.LastAccessedAt,
+		CreatedAt:      hdr.CreatedAt,
+		Version:        hdr.Version,
+	}, nil
+}
+
+// compress deflate body if it makes it shorter, first byte of the result is compression flag
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(deflated)
+
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if buf.Len() > len(body) {
+		return append([]byte{plain}, body...), nil
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(pt []byte) ([]byte, error) {
+	if len(pt) == 0 {
+		return nil, errMalformedToken
+	}
+
+	switch pt[0] {
+	case plain:
+		return pt[1:], nil
+	case deflated:
+		return io.ReadAll(flate.NewReader(bytes.NewReader(pt[1:])))
+	default:
+		return nil, errMalformedToken
+	}
+}
@@ -0,0 +1,436 @@
+package cookiestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/codec"
+	"github.com/asstart/go-session/internal/attrs"
+	"github.com/go-logr/logr"
+)
+
+// DefaultMaxSize is max size of token which fits into a single cookie (see DefaultChunkSize)
+const DefaultMaxSize = DefaultChunkSize
+
+// ErrSessionTooLarge is returned when sealed session exceeds the max size (see WithMaxSize)
+var ErrSessionTooLarge = errors.New("sessionservice: session is too large for the cookie")
+
+type cookieStore struct {
+	sealer *sealer
+
+	Logger      logr.Logger
+	CtxReqIDKey interface{}
+	Revoker     Revoker
+	Codec       session.Codec
+	MaxSize     int
+	Clock       session.Clock
+}
+
+// Option configures store created by NewCookieStore
+type Option func(*cookieStore)
+
+// WithRevoker set Revoker used to invalidate sessions,
+// without it Invalidate return session.ErrNotSupported
+// and tokens replaced by Regenerate stay valid until they expire
+func WithRevoker(r Revoker) Option {
+	return func(cs *cookieStore) {
+		cs.Revoker = r
+	}
+}
+
+// WithCodec set codec used to encode session attributes, JSON codec is used by default
+func WithCodec(c session.Codec) Option {
+	return func(cs *cookieStore) {
+		cs.Codec = c
+	}
+}
+
+// WithMaxSize set max size of token, DefaultMaxSize by default,
+// it may be increased if session cookie is split into chunks (see NewCookieCodec)
+func WithMaxSize(n int) Option {
+	return func(cs *cookieStore) {
+		cs.MaxSize = n
+	}
+}
+
+// WithClock set clock used for timestamps and expiry checks, session.SystemClock is used by default
+func WithClock(c session.Clock) Option {
+	return func(cs *cookieStore) {
+		cs.Clock = c
+	}
+}
+
+/*
+NewCookieStore Create implementation of session.Store keeping sessions in cookies
+
+The whole session is serialized, compressed and sealed with AES-GCM into a token,
+the token is used as session id, so it's written to the session cookie by session.Middleware.
+Middleware should be configured with session.WithCookieCodec(NewCookieCodec(...)).
+
+Every call changing the session, including Load which bumps LastAccessedAt,
+return the session with a new id, so it should be bound to the request with session.ReplaceSession.
+Session.Version is kept in the token, so Update detects only changes made with the same token.
+
+keys are AES keys (16, 24 or 32 bytes), the first key encrypts tokens and all keys decrypt them,
+so keys can be rotated by putting a new key first and removing the old one once its tokens expire.
+
+ListUserSessions and InvalidateUserSessions return session.ErrNotSupported,
+Invalidate requires Revoker (see WithRevoker).
+
+reqIDKey is key to extract request id from the context
+*/
+func NewCookieStore(keys [][]byte, l logr.Logger, reqIDKey interface{}, opts ...Option) (session.Store, error) {
+	cs := &cookieStore{
+		Logger:      l,
+		CtxReqIDKey: reqIDKey,
+		Codec:       codec.NewJSON(),
+		MaxSize:     DefaultMaxSize,
+		Clock:       session.SystemClock(),
+	}
+	for _, o := range opts {
+		o(cs)
+	}
+
+	sl, err := newSealer(keys, cs.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("session.cookiestore.NewCookieStore() error: %w", err)
+	}
+	cs.sealer = sl
+
+	return cs, nil
+}
+
+func (cs *cookieStore) Save(ctx context.Context, s *session.Session) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.Save() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Save() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	now := cs.Clock.Now()
+
+	ns := *s
+	ns.CreatedAt = now
+	ns.LastAccessedAt = now
+	ns.Version = 1
+	if old, err := cs.sealer.open(s.ID); err == nil {
+		ns.ID = old.ID
+		ns.CreatedAt = old.CreatedAt
+		ns.Version = old.Version + 1
+	}
+
+	return cs.issue(ctx, "Save", &ns)
+}
+
+func (cs *cookieStore) Create(ctx context.Context, s *session.Session) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.Create() started", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Create() finished", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	now := cs.Clock.Now()
+
+	ns := *s
+	ns.CreatedAt = now
+	ns.LastAccessedAt = now
+	ns.Version = 1
+
+	return cs.issue(ctx, "Create", &ns)
+}
+
+func (cs *cookieStore) Load(ctx context.Context, sid string) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.Load() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Load() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	s, err := cs.live(ctx, "Load", sid)
+	if err != nil {
+		return nil, err
+	}
+
+	s.LastAccessedAt = cs.Clock.Now()
+
+	return cs.issue(ctx, "Load", s)
+}
+
+// Peek return session sealed in sid as is, the token isn't reissued
+func (cs *cookieStore) Peek(ctx context.Context, sid string) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.Peek() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Peek() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	s, err := cs.live(ctx, "Peek", sid)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ID = sid
+
+	return s, nil
+}
+
+func (cs *cookieStore) Exists(ctx context.Context, sid string) (bool, error) {
+	cs.Logger.V(0).Info("session.cookiestore.Exists() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Exists() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	_, err := cs.live(ctx, "Exists", sid)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrSessionExpired) || errors.Is(err, session.ErrSessionInvalidated):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (cs *cookieStore) AddAttributes(ctx context.Context, sid string, data map[string]interface{}) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.AddAttributes() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.AddAttributes() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		cs.Logger.V(0).Info("session.cookiestore.AddAttributes() invalid key", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := cs.live(ctx, "AddAttributes", sid)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range data {
+		s.AddAttribute(k, v)
+	}
+	s.LastAccessedAt = cs.Clock.Now()
+	s.Version++
+
+	return cs.issue(ctx, "AddAttributes", s)
+}
+
+func (cs *cookieStore) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.RemoveAttributes() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.RemoveAttributes() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		cs.Logger.V(0).Info("session.cookiestore.RemoveAttributes() invalid key", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := cs.live(ctx, "RemoveAttributes", sid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		s.RemoveAttribute(k)
+	}
+	s.LastAccessedAt = cs.Clock.Now()
+	s.Version++
+
+	return cs.issue(ctx, "RemoveAttributes", s)
+}
+
+func (cs *cookieStore) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.IncrementAttribute() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.IncrementAttribute() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		cs.Logger.V(0).Info("session.cookiestore.IncrementAttribute() invalid key", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := cs.live(ctx, "IncrementAttribute", sid)
+	if err != nil {
+		return nil, err
+	}
+
+	v, _ := s.GetAttribute(key)
+	n, ok := attrs.AddNumber(v, delta)
+	if !ok {
+		cs.Logger.V(0).Info("session.cookiestore.IncrementAttribute() attribute isn't a number", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+		return nil, session.ErrAttributeType
+	}
+
+	s.AddAttribute(key, n)
+	s.LastAccessedAt = cs.Clock.Now()
+	s.Version++
+
+	return cs.issue(ctx, "IncrementAttribute", s)
+}
+
+func (cs *cookieStore) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.AppendToAttribute() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.AppendToAttribute() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		cs.Logger.V(0).Info("session.cookiestore.AppendToAttribute() invalid key", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := cs.live(ctx, "AppendToAttribute", sid)
+	if err != nil {
+		return nil, err
+	}
+
+	v, _ := s.GetAttribute(key)
+	list, ok := attrs.Append(v, values, maxLen)
+	if !ok {
+		cs.Logger.V(0).Info("session.cookiestore.AppendToAttribute() attribute isn't a list", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+		return nil, session.ErrAttributeType
+	}
+
+	s.AddAttribute(key, list)
+	s.LastAccessedAt = cs.Clock.Now()
+	s.Version++
+
+	return cs.issue(ctx, "AppendToAttribute", s)
+}
+
+func (cs *cookieStore) Invalidate(ctx context.Context, sid string) error {
+	cs.Logger.V(0).Info("session.cookiestore.Invalidate() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Invalidate() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	if cs.Revoker == nil {
+		cs.Logger.V(0).Info("session.cookiestore.Invalidate() revoker isn't configured", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+		return session.ErrNotSupported
+	}
+
+	s, err := cs.sealer.open(sid)
+	if err != nil {
+		cs.Logger.V(0).Info("session.cookiestore.Invalidate() can't open token", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return session.ErrSessionNotFound
+	}
+
+	err = cs.Revoker.Revoke(ctx, s.ID, cs.Clock.Now(), s.CreatedAt.Add(s.AbsTimeout))
+	if err != nil {
+		err = fmt.Errorf("session.cookiestore.Invalidate() error: %w", err)
+		cs.Logger.V(0).Info("session.cookiestore.Invalidate() error", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return err
+	}
+
+	return nil
+}
+
+func (cs *cookieStore) Update(ctx context.Context, s *session.Session) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.Update() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Update() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	old, err := cs.live(ctx, "Update", s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if old.Version != s.Version {
+		cs.Logger.V(0).Info("session.cookiestore.Update() version conflict", session.LogKeySID, old.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+		return nil, session.ErrVersionConflict
+	}
+
+	ns := *s
+	ns.ID = old.ID
+	ns.CreatedAt = old.CreatedAt
+	ns.LastAccessedAt = cs.Clock.Now()
+	ns.Version = old.Version + 1
+
+	return cs.issue(ctx, "Update", &ns)
+}
+
+func (cs *cookieStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*session.Session)) (*session.Session, error) {
+	cs.Logger.V(0).Info("session.cookiestore.Regenerate() started", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+	defer cs.Logger.V(0).Info("session.cookiestore.Regenerate() finished", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+
+	old, err := cs.live(ctx, "Regenerate", sid)
+	if err != nil {
+		return nil, err
+	}
+
+	now := cs.Clock.Now()
+
+	ns := *old
+	if modify != nil {
+		modify(&ns)
+	}
+	ns.ID = newSID
+	ns.Active = true
+	ns.CreatedAt = old.CreatedAt
+	ns.LastAccessedAt = now
+	ns.Version = old.Version + 1
+
+	regenerated, err := cs.issue(ctx, "Regenerate", &ns)
+	if err != nil {
+		return nil, err
+	}
+
+	if cs.Revoker != nil {
+		err = cs.Revoker.Revoke(ctx, old.ID, now.Add(grace), old.CreatedAt.Add(old.AbsTimeout))
+		if err != nil {
+			err = fmt.Errorf("session.cookiestore.Regenerate() error: %w", err)
+			cs.Logger.V(0).Info("session.cookiestore.Regenerate() error", session.LogKeySID, old.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+			return nil, err
+		}
+	}
+
+	return regenerated, nil
+}
+
+func (cs *cookieStore) ListUserSessions(ctx context.Context, uid string) ([]*session.Session, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (cs *cookieStore) InvalidateUserSessions(ctx context.Context, uid string, exceptSIDs ...string) error {
+	return session.ErrNotSupported
+}
+
+// live open token and return the session with its stable id if it isn't expired, invalidated or revoked
+func (cs *cookieStore) live(ctx context.Context, op, sid string) (*session.Session, error) {
+	s, err := cs.sealer.open(sid)
+	if err != nil {
+		cs.Logger.V(0).Info("session.cookiestore."+op+"() can't open token", session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, session.ErrSessionNotFound
+	}
+
+	err = s.CheckExpiredAt(cs.Clock.Now())
+	if err != nil {
+		cs.Logger.V(0).Info("session.cookiestore."+op+"() session not available", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	if cs.Revoker == nil {
+		return s, nil
+	}
+
+	at, err := cs.Revoker.RevokedAt(ctx, s.ID)
+	if err != nil {
+		err = fmt.Errorf("session.cookiestore.%s() error: %w", op, err)
+		cs.Logger.V(0).Info("session.cookiestore."+op+"() error", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+	if !at.IsZero() && !cs.Clock.Now().Before(at) {
+		cs.Logger.V(0).Info("session.cookiestore."+op+"() session revoked", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey))
+		return nil, session.ErrSessionInvalidated
+	}
+
+	return s, nil
+}
+
+// issue seal s and return its copy with the token as id
+func (cs *cookieStore) issue(ctx context.Context, op string, s *session.Session) (*session.Session, error) {
+	token, err := cs.sealer.seal(s)
+	if err != nil {
+		err = fmt.Errorf("session.cookiestore.%s() error: %w", op, err)
+		cs.Logger.V(0).Info("session.cookiestore."+op+"() error", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	if cs.MaxSize > 0 && len(token) > cs.MaxSize {
+		cs.Logger.V(0).Info("session.cookiestore."+op+"() session is too large", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(cs.CtxReqIDKey), "session.size", len(token))
+		return nil, ErrSessionTooLarge
+	}
+
+	ns := *s
+	ns.ID = token
+	return &ns, nil
+}
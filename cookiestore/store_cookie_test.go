@@ -0,0 +1,392 @@
+package cookiestore_test
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/cookiestore"
+	"github.com/asstart/go-session/storetest"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) []byte {
+	k := make([]byte, 32)
+	_, err := rand.Read(k)
+	require.Nil(t, err)
+	return k
+}
+
+func newStore(t *testing.T, keys [][]byte, opts ...cookiestore.Option) session.Store {
+	store, err := cookiestore.NewCookieStore(keys, logr.Discard(), "key", opts...)
+	require.Nil(t, err)
+	return store
+}
+
+func newTestSession(t *testing.T) *session.Session {
+	s, err := session.NewSession()
+	require.Nil(t, err)
+	return &s
+}
+
+func TestNewCookieStoreInvalidKeys(t *testing.T) {
+	tt := []struct {
+		name string
+		keys [][]byte
+	}{
+		{"no keys", nil},
+		{"short key", [][]byte{[]byte("short")}},
+		{"one of keys is invalid", [][]byte{make([]byte, 32), make([]byte, 7)}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := cookiestore.NewCookieStore(tc.keys, logr.Discard(), "key")
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestCreateAndLoad(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, [][]byte{newKey(t)})
+
+	s := newTestSession(t)
+	s.WithUserID("uid")
+	s.AddAttribute("int", 1)
+	s.AddAttribute("cart.items", []string{"a", "b"})
+
+	created, err := store.Create(ctx, s)
+	require.Nil(t, err)
+	assert.NotEqual(t, s.ID, created.ID)
+	assert.Equal(t, int64(1), created.Version)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	loaded, err := store.Load(ctx, created.ID)
+	require.Nil(t, err)
+	assert.NotEqual(t, created.ID, loaded.ID)
+	assert.Equal(t, "uid", loaded.UID)
+	assert.Equal(t, s.Opts, loaded.Opts)
+	assert.Equal(t, s.IdleTimeout, loaded.IdleTimeout)
+	assert.Equal(t, s.AbsTimeout, loaded.AbsTimeout)
+	assert.True(t, created.CreatedAt.Equal(loaded.CreatedAt))
+	assert.Equal(t, int64(1), loaded.Version)
+
+	i, ok := loaded.GetInt("int")
+	assert.True(t, ok)
+	assert.Equal(t, 1, i)
+
+	items, ok := session.Get[[]string](loaded, "cart.items")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, items)
+
+	peeked, err := store.Peek(ctx, loaded.ID)
+	require.Nil(t, err)
+	assert.Equal(t, loaded.ID, peeked.ID)
+	assert.True(t, loaded.LastAccessedAt.Equal(peeked.LastAccessedAt))
+
+	ok, err = store.Exists(ctx, loaded.ID)
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.Exists(ctx, "invalid")
+	require.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestLoadInvalidToken(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, [][]byte{newKey(t)})
+
+	created, err := store.Create(ctx, newTestSession(t))
+	require.Nil(t, err)
+
+	tampered := []byte(created.ID)
+	tampered[len(tampered)/2] ^= 1
+
+	tt := []struct {
+		name string
+		sid  string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"tampered", string(tampered)},
+		{"truncated", created.ID[:len(created.ID)-4]},
+		{"other store", func() string {
+			s, err := newStore(t, [][]byte{newKey(t)}).Create(ctx, newTestSession(t))
+			require.Nil(t, err)
+			return s.ID
+		}()},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := store.Load(ctx, tc.sid)
+			assert.ErrorIs(t, err, session.ErrSessionNotFound)
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := newKey(t), newKey(t)
+
+	created, err := newStore(t, [][]byte{oldKey}).Create(ctx, newTestSession(t))
+	require.Nil(t, err)
+
+	rotated := newStore(t, [][]byte{newKey, oldKey})
+	loaded, err := rotated.Load(ctx, created.ID)
+	require.Nil(t, err)
+
+	_, err = newStore(t, [][]byte{newKey}).Load(ctx, loaded.ID)
+	assert.Nil(t, err)
+
+	_, err = newStore(t, [][]byte{newKey}).Load(ctx, created.ID)
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+}
+
+func TestExpired(t *testing.T) {
+	ctx := context.Background()
+	clock := storetest.NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := newStore(t, [][]byte{newKey(t)}, cookiestore.WithClock(clock))
+
+	s := newTestSession(t)
+	s.IdleTimeout = time.Hour
+	s.AbsTimeout = 2 * time.Hour
+	created, err := store.Create(ctx, s)
+	require.Nil(t, err)
+	assert.Equal(t, clock.Now(), created.CreatedAt)
+
+	clock.Advance(time.Hour)
+	loaded, err := store.Load(ctx, created.ID)
+	require.Nil(t, err)
+	assert.Equal(t, clock.Now(), loaded.LastAccessedAt)
+
+	clock.Advance(time.Hour + time.Second)
+	_, err = store.Load(ctx, created.ID)
+	assert.ErrorIs(t, err, session.ErrSessionExpired)
+
+	_, err = store.Load(ctx, loaded.ID)
+	assert.ErrorIs(t, err, session.ErrSessionExpired)
+}
+
+func TestSize(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, [][]byte{newKey(t)})
+
+	s := newTestSession(t)
+	s.AddAttribute("compressible", strings.Repeat("a", 10*cookiestore.DefaultMaxSize))
+	created, err := store.Create(ctx, s)
+	require.Nil(t, err)
+	assert.LessOrEqual(t, len(created.ID), cookiestore.DefaultMaxSize)
+
+	random := make([]byte, cookiestore.DefaultMaxSize)
+	_, err = rand.Read(random)
+	require.Nil(t, err)
+
+	_, err = store.AddAttributes(ctx, created.ID, map[string]interface{}{"random": random})
+	assert.ErrorIs(t, err, cookiestore.ErrSessionTooLarge)
+
+	large := newStore(t, [][]byte{newKey(t)}, cookiestore.WithMaxSize(4*cookiestore.DefaultMaxSize))
+	created, err = large.Create(ctx, s)
+	require.Nil(t, err)
+	_, err = large.AddAttributes(ctx, created.ID, map[string]interface{}{"random": random})
+	assert.Nil(t, err)
+}
+
+func TestAttributes(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, [][]byte{newKey(t)})
+
+	created, err := store.Create(ctx, newTestSession(t))
+	require.Nil(t, err)
+
+	s, err := store.AddAttributes(ctx, created.ID, map[string]interface{}{"k1": "v1", "k2": "v2"})
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), s.Version)
+
+	s, err = store.RemoveAttributes(ctx, s.ID, "k2")
+	require.Nil(t, err)
+
+	s, err = store.IncrementAttribute(ctx, s.ID, "counter", 2)
+	require.Nil(t, err)
+
+	s, err = store.AppendToAttribute(ctx, s.ID, "list", []interface{}{"a", "b", "c"}, 2)
+	require.Nil(t, err)
+
+	_, err = store.IncrementAttribute(ctx, s.ID, "k1", 1)
+	assert.ErrorIs(t, err, session.ErrAttributeType)
+
+	_, err = store.AddAttributes(ctx, s.ID, map[string]interface{}{"$bad": 1})
+	assert.ErrorIs(t, err, session.ErrInvalidAttributeKey)
+
+	s, err = store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(5), s.Version)
+	assert.Equal(t, map[string]interface{}{
+		"k1":      "v1",
+		"counter": int64(2),
+		"list":    []interface{}{"b", "c"},
+	}, s.Data)
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, [][]byte{newKey(t)})
+
+	created, err := store.Create(ctx, newTestSession(t))
+	require.Nil(t, err)
+
+	s := *created
+	s.AddAttribute("k", "v")
+	updated, err := store.Update(ctx, &s)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+	assert.Equal(t, "v", updated.Data["k"])
+
+	stale := *created
+	stale.Version = 0
+	_, err = store.Update(ctx, &stale)
+	assert.ErrorIs(t, err, session.ErrVersionConflict)
+}
+
+func TestInvalidate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("without revoker", func(t *testing.T) {
+		store := newStore(t, [][]byte{newKey(t)})
+		created, err := store.Create(ctx, newTestSession(t))
+		require.Nil(t, err)
+
+		err = store.Invalidate(ctx, created.ID)
+		assert.ErrorIs(t, err, session.ErrNotSupported)
+	})
+
+	t.Run("with revoker", func(t *testing.T) {
+		store := newStore(t, [][]byte{newKey(t)}, cookiestore.WithRevoker(cookiestore.NewMemoryRevoker()))
+		created, err := store.Create(ctx, newTestSession(t))
+		require.Nil(t, err)
+		loaded, err := store.Load(ctx, created.ID)
+		require.Nil(t, err)
+
+		err = store.Invalidate(ctx, loaded.ID)
+		require.Nil(t, err)
+
+		_, err = store.Load(ctx, loaded.ID)
+		assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+		_, err = store.Load(ctx, created.ID)
+		assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		store := newStore(t, [][]byte{newKey(t)}, cookiestore.WithRevoker(cookiestore.NewMemoryRevoker()))
+		err := store.Invalidate(ctx, "garbage")
+		assert.ErrorIs(t, err, session.ErrSessionNotFound)
+	})
+}
+
+func TestRegenerate(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, [][]byte{newKey(t)}, cookiestore.WithRevoker(cookiestore.NewMemoryRevoker()))
+
+	created, err := store.Create(ctx, newTestSession(t))
+	require.Nil(t, err)
+
+	regenerated, err := store.Regenerate(ctx, created.ID, "new", 0, func(s *session.Session) {
+		s.WithUserID("uid")
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "uid", regenerated.UID)
+	assert.Equal(t, int64(2), regenerated.Version)
+	assert.True(t, created.CreatedAt.Equal(regenerated.CreatedAt))
+
+	_, err = store.Load(ctx, created.ID)
+	assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+	_, err = store.Load(ctx, regenerated.ID)
+	assert.Nil(t, err)
+
+	withGrace, err := store.Regenerate(ctx, regenerated.ID, "newer", time.Hour, nil)
+	require.Nil(t, err)
+	_, err = store.Load(ctx, regenerated.ID)
+	assert.Nil(t, err)
+	_, err = store.Load(ctx, withGrace.ID)
+	assert.Nil(t, err)
+}
+
+func TestUserSessionsNotSupported(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, [][]byte{newKey(t)})
+
+	_, err := store.ListUserSessions(ctx, "uid")
+	assert.ErrorIs(t, err, session.ErrNotSupported)
+
+	err = store.InvalidateUserSessions(ctx, "uid")
+	assert.ErrorIs(t, err, session.ErrNotSupported)
+}
+
+func TestMiddleware(t *testing.T) {
+	store := newStore(t, [][]byte{newKey(t)},
+		cookiestore.WithRevoker(cookiestore.NewMemoryRevoker()),
+		cookiestore.WithMaxSize(4*cookiestore.DefaultChunkSize),
+	)
+	svc := session.NewService(store, logr.Discard(), "key")
+
+	random := make([]byte, 2*cookiestore.DefaultChunkSize)
+	_, err := rand.Read(random)
+	require.Nil(t, err)
+
+	h := session.Middleware(svc,
+		session.WithAnonymSession(session.DefaultCookieConf(), session.DefaultSessionConf()),
+		session.WithCookieCodec(cookiestore.NewCookieCodec(cookiestore.DefaultChunkSize)),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := session.FromContext(r.Context())
+		require.True(t, ok)
+
+		switch r.URL.Path {
+		case "/large":
+			s, err = svc.AddAttributes(r.Context(), s.ID, "random", random)
+		case "/small":
+			s, err = svc.RemoveAttributes(r.Context(), s.ID, "random")
+		case "/logout":
+			err = svc.InvalidateSession(r.Context(), s.ID)
+			s = nil
+		}
+		require.Nil(t, err)
+		session.ReplaceSession(r.Context(), s)
+	}))
+
+	serve := func(path string, cookies []*http.Cookie) []*http.Cookie {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Result().Cookies()
+	}
+
+	cookies := serve("/", nil)
+	require.Len(t, cookies, 1)
+
+	chunked := serve("/large", cookies)
+	require.Len(t, chunked, 4)
+	assert.Equal(t, "3", chunked[0].Value)
+
+	cookies = serve("/small", chunked)
+	require.Len(t, cookies, 4)
+	for _, c := range cookies[1:] {
+		assert.True(t, c.MaxAge < 0)
+	}
+
+	cookies = serve("/logout", cookies[:1])
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].MaxAge < 0)
+}
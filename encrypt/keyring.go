@@ -0,0 +1,101 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// dataKeyLen is length of AES-256 key generated for every encrypted Data
+const dataKeyLen = 32
+
+var ErrUnknownKey = errors.New("sessionservice: unknown encryption key")
+
+/*
+KeyRing keeps key encryption keys by their ids.
+
+Every write encrypts Data with a new random data key,
+the data key is encrypted (wrapped) with the current key and stored next to Data with the key id.
+Other keys are used only to unwrap data keys of sessions written before rotation,
+such sessions are encrypted with the current key on their next write.
+*/
+type KeyRing struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyRing Create KeyRing, keys are AES keys (16, 24 or 32 bytes) by their ids,
+// current is id of the key used to wrap data keys, ids should be shorter than 256 bytes
+func NewKeyRing(current string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("session.encrypt.NewKeyRing() current key %q not found", current)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, k := range keys {
+		if len(id) > 255 {
+			return nil, fmt.Errorf("session.encrypt.NewKeyRing() key id %q is too long", id)
+		}
+		aead, err := newAEAD(k)
+		if err != nil {
+			return nil, fmt.Errorf("session.encrypt.NewKeyRing() key %q: %w", id, err)
+		}
+		aeads[id] = aead
+	}
+
+	return &KeyRing{current: current, aeads: aeads}, nil
+}
+
+// wrap generate new data key and return it with the current key id and the data key wrapped by the current key
+func (kr *KeyRing) wrap() ([]byte, string, []byte, error) {
+	dk := make([]byte, dataKeyLen)
+	_, err := io.ReadFull(rand.Reader, dk)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	wrapped, err := seal(kr.aeads[kr.current], dk, []byte(kr.current))
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return dk, kr.current, wrapped, nil
+}
+
+// unwrap decrypt data key wrapped by key with id
+func (kr *KeyRing) unwrap(id string, wrapped []byte) ([]byte, error) {
+	aead, ok := kr.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return open(aead, wrapped, []byte(id))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+// seal encrypt plaintext and return nonce followed by ciphertext
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+// open decrypt nonce followed by ciphertext
+func open(aead cipher.AEAD, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], ad)
+}
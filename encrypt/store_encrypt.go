@@ -0,0 +1,418 @@
+package encrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/codec"
+	"github.com/asstart/go-session/internal/attrs"
+	"github.com/go-logr/logr"
+)
+
+// EncryptedDataKey is the only attribute of Data passed to the underlying store,
+// its value is encrypted Data
+const EncryptedDataKey = "_encrypted"
+
+// envelopeVersion is the first byte of encrypted Data
+const envelopeVersion = 1
+
+// updateAttempts is how many times attribute updates are retried on version conflict
+const updateAttempts = 5
+
+var envelopeEnc = base64.RawStdEncoding
+
+var errMalformedEnvelope = errors.New("malformed encrypted data")
+
+// ErrSessionMismatch is returned when encrypted Data can't be authenticated with the session id,
+// it's moved from another session or corrupted, or ids are changed below the store (see NewStore)
+var ErrSessionMismatch = errors.New("encrypted data doesn't belong to the session")
+
+type encryptStore struct {
+	session.Store
+
+	Logger      logr.Logger
+	CtxReqIDKey interface{}
+	Keys        *KeyRing
+	Codec       session.Codec
+}
+
+// Option configures store created by NewStore
+type Option func(*encryptStore)
+
+// WithCodec set codec used to encode Data before encryption, JSON codec is used by default
+func WithCodec(c session.Codec) Option {
+	return func(es *encryptStore) {
+		es.Codec = c
+	}
+}
+
+/*
+NewStore Create session.Store which encrypts Data of sessions kept in s with AES-GCM,
+session id is authenticated as associated data, so encrypted Data can't be moved to another session.
+
+Stores which change session ids must wrap the encrypt store, not be wrapped by it,
+e.g. sidhash.NewStore(encrypt.NewStore(s, ...), ...), so Data is bound to the id kept in s.
+Otherwise sessions returned with other ids (like hashed ids of ListUserSessions) fail with ErrSessionMismatch.
+
+Data is encoded with the codec and encrypted with a new data key wrapped by the current key of keys (see KeyRing),
+sessions encrypted with old keys and sessions stored before encryption are read as is
+and encrypted with the current key on their next write.
+
+Backends can't change encrypted Data, so AddAttributes, RemoveAttributes, IncrementAttribute
+and AppendToAttribute load the session, change it and store it with Update,
+they're retried on session.ErrVersionConflict, so they stay atomic,
+but cost an extra call to s and bump LastAccessedAt like Load does.
+
+reqIDKey is key to extract request id from the context
+*/
+func NewStore(s session.Store, keys *KeyRing, l logr.Logger, reqIDKey interface{}, opts ...Option) session.Store {
+	es := &encryptStore{
+		Store:       s,
+		Logger:      l,
+		CtxReqIDKey: reqIDKey,
+		Keys:        keys,
+		Codec:       codec.NewJSON(),
+	}
+	for _, o := range opts {
+		o(es)
+	}
+	return es
+}
+
+func (es *encryptStore) Save(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ns, err := es.encrypted(s)
+	if err != nil {
+		return nil, es.error(ctx, "Save", s.ID, err)
+	}
+
+	svdS, err := es.Store.Save(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	return es.decrypted(ctx, "Save", svdS)
+}
+
+func (es *encryptStore) Create(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ns, err := es.encrypted(s)
+	if err != nil {
+		return nil, es.error(ctx, "Create", s.ID, err)
+	}
+
+	svdS, err := es.Store.Create(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	return es.decrypted(ctx, "Create", svdS)
+}
+
+func (es *encryptStore) Load(ctx context.Context, sid string) (*session.Session, error) {
+	s, err := es.Store.Load(ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	return es.decrypted(ctx, "Load", s)
+}
+
+func (es *encryptStore) Peek(ctx context.Context, sid string) (*session.Session, error) {
+	s, err := es.Store.Peek(ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	return es.decrypted(ctx, "Peek", s)
+}
+
+func (es *encryptStore) Update(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ns, err := es.encrypted(s)
+	if err != nil {
+		return nil, es.error(ctx, "Update", s.ID, err)
+	}
+
+	updS, err := es.Store.Update(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	return es.decrypted(ctx, "Update", updS)
+}
+
+func (es *encryptStore) AddAttributes(ctx context.Context, sid string, data map[string]interface{}) (*session.Session, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return es.modify(ctx, "AddAttributes", sid, func(s *session.Session) error {
+		for k, v := range data {
+			s.AddAttribute(k, v)
+		}
+		return nil
+	})
+}
+
+func (es *encryptStore) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*session.Session, error) {
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return es.modify(ctx, "RemoveAttributes", sid, func(s *session.Session) error {
+		for _, k := range keys {
+			s.RemoveAttribute(k)
+		}
+		return nil
+	})
+}
+
+func (es *encryptStore) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*session.Session, error) {
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return es.modify(ctx, "IncrementAttribute", sid, func(s *session.Session) error {
+		v, _ := s.GetAttribute(key)
+		n, ok := attrs.AddNumber(v, delta)
+		if !ok {
+			return session.ErrAttributeType
+		}
+		s.AddAttribute(key, n)
+		return nil
+	})
+}
+
+func (es *encryptStore) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*session.Session, error) {
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return es.modify(ctx, "AppendToAttribute", sid, func(s *session.Session) error {
+		v, _ := s.GetAttribute(key)
+		list, ok := attrs.Append(v, values, maxLen)
+		if !ok {
+			return session.ErrAttributeType
+		}
+		s.AddAttribute(key, list)
+		return nil
+	})
+}
+
+/*
+Regenerate move session to newSID, its Data is encrypted again with newSID as associated data.
+
+The session is decrypted before it's moved, so it isn't moved if it can't be decrypted and stays valid under sid.
+modify is applied once to the decrypted session which is stored, if the result can't be encrypted
+the session is moved without changes made by modify and the error is returned.
+*/
+func (es *encryptStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*session.Session)) (*session.Session, error) {
+	old, err := es.Store.Peek(ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := es.decrypt(sid, old.Data)
+	if err != nil {
+		return nil, es.error(ctx, "Regenerate", sid, err)
+	}
+
+	var merr error
+	reencrypt := func(s *session.Session) {
+		// session is decrypted again only if it's changed after Peek
+		cur := data
+		if !sameEnvelope(s.Data, old.Data) {
+			cur, merr = es.decrypt(sid, s.Data)
+			if merr != nil {
+				// it's moved as is, nobody can read it anyway
+				return
+			}
+		}
+
+		s.Data = cur
+		if modify != nil {
+			modify(s)
+		}
+
+		var enc map[string]interface{}
+		enc, merr = es.encrypt(newSID, s.Data)
+		if merr != nil {
+			// keep the moved session readable without changes made by modify
+			enc, _ = es.encrypt(newSID, cur)
+		}
+		s.Data = enc
+	}
+
+	s, err := es.Store.Regenerate(ctx, sid, newSID, grace, reencrypt)
+	if err != nil {
+		return nil, err
+	}
+	if merr != nil {
+		return nil, es.error(ctx, "Regenerate", sid, merr)
+	}
+	return es.decrypted(ctx, "Regenerate", s)
+}
+
+func (es *encryptStore) ListUserSessions(ctx context.Context, uid string) ([]*session.Session, error) {
+	sessions, err := es.Store.ListUserSessions(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range sessions {
+		sessions[i], err = es.decrypted(ctx, "ListUserSessions", s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+// modify load session, apply fn to its decrypted copy and store it with Update,
+// it's retried on version conflict
+func (es *encryptStore) modify(ctx context.Context, op, sid string, fn func(*session.Session) error) (*session.Session, error) {
+	for i := 1; ; i++ {
+		s, err := es.Load(ctx, sid)
+		if err != nil {
+			return nil, err
+		}
+
+		err = fn(s)
+		if err != nil {
+			es.Logger.V(0).Info("session.encrypt."+op+"() can't change session", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(es.CtxReqIDKey), session.LogKeyDebugError, err)
+			return nil, err
+		}
+
+		updS, err := es.Update(ctx, s)
+		if !errors.Is(err, session.ErrVersionConflict) || i == updateAttempts {
+			return updS, err
+		}
+
+		es.Logger.V(0).Info("session.encrypt."+op+"() version conflict", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(es.CtxReqIDKey), "session.attempt", i)
+	}
+}
+
+// encrypted return copy of s with encrypted Data
+func (es *encryptStore) encrypted(s *session.Session) (*session.Session, error) {
+	data, err := es.encrypt(s.ID, s.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := *s
+	ns.Data = data
+	return &ns, nil
+}
+
+// decrypted replace encrypted Data of s with decrypted one
+func (es *encryptStore) decrypted(ctx context.Context, op string, s *session.Session) (*session.Session, error) {
+	data, err := es.decrypt(s.ID, s.Data)
+	if err != nil {
+		return nil, es.error(ctx, op, s.ID, err)
+	}
+
+	s.Data = data
+	return s, nil
+}
+
+// encrypt return Data with the only attribute EncryptedDataKey,
+// its value is version, key id, wrapped data key and encrypted data
+func (es *encryptStore) encrypt(sid string, data map[string]interface{}) (map[string]interface{}, error) {
+	plaintext, err := es.Codec.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dk, kid, wrapped, err := es.Keys.wrap()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dk)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(aead, plaintext, []byte(sid))
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]byte, 0, 3+len(kid)+len(wrapped)+len(sealed))
+	env = append(env, envelopeVersion, byte(len(kid)))
+	env = append(env, kid...)
+	env = append(env, byte(len(wrapped)))
+	env = append(env, wrapped...)
+	env = append(env, sealed...)
+
+	return map[string]interface{}{EncryptedDataKey: envelopeEnc.EncodeToString(env)}, nil
+}
+
+// decrypt return decrypted Data, Data which isn't encrypted is returned as is
+func (es *encryptStore) decrypt(sid string, data map[string]interface{}) (map[string]interface{}, error) {
+	v, ok := data[EncryptedDataKey].(string)
+	if !ok || len(data) != 1 {
+		return data, nil
+	}
+
+	env, err := envelopeEnc.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(env) == 0 || env[0] != envelopeVersion {
+		return nil, errMalformedEnvelope
+	}
+	kid, rest, ok := lenPrefixed(env[1:])
+	if !ok {
+		return nil, errMalformedEnvelope
+	}
+	wrapped, sealed, ok := lenPrefixed(rest)
+	if !ok {
+		return nil, errMalformedEnvelope
+	}
+
+	dk, err := es.Keys.unwrap(string(kid), wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dk)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, sealed, []byte(sid))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSessionMismatch, err)
+	}
+
+	return es.Codec.Unmarshal(plaintext)
+}
+
+// sameEnvelope check if both Data are the same encrypted Data
+func sameEnvelope(a, b map[string]interface{}) bool {
+	va, ok := a[EncryptedDataKey].(string)
+	if !ok || len(a) != 1 {
+		return false
+	}
+	vb, ok := b[EncryptedDataKey].(string)
+	return ok && len(b) == 1 && va == vb
+}
+
+// lenPrefixed split b into field prefixed with its one byte length and the rest
+func lenPrefixed(b []byte) ([]byte, []byte, bool) {
+	if len(b) == 0 || len(b) < 1+int(b[0]) {
+		return nil, nil, false
+	}
+	return b[1 : 1+int(b[0])], b[1+int(b[0]):], true
+}
+
+func (es *encryptStore) error(ctx context.Context, op, sid string, err error) error {
+	err = fmt.Errorf("session.encrypt.%s() error: %w", op, err)
+	es.Logger.V(0).Info("session.encrypt."+op+"() error", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(es.CtxReqIDKey), session.LogKeyDebugError, err)
+	return err
+}
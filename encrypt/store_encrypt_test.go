@@ -0,0 +1,191 @@
+package encrypt_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/encrypt"
+	"github.com/asstart/go-session/memory"
+	"github.com/asstart/go-session/storetest"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptStoreSuite(t *testing.T) {
+	keys, err := encrypt.NewKeyRing("k1", map[string][]byte{"k1": make([]byte, 32)})
+	require.Nil(t, err)
+
+	storetest.RunStoreSuite(t, func() session.Store {
+		inner := memory.NewMemoryStore(context.Background(), logr.Discard(), "key", memory.WithSweepInterval(0))
+		return encrypt.NewStore(inner, keys, logr.Discard(), "key")
+	})
+}
+
+func newKeyRing(t *testing.T, current string, ids ...string) *encrypt.KeyRing {
+	keys := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		k := make([]byte, 32)
+		copy(k, id)
+		keys[id] = k
+	}
+	kr, err := encrypt.NewKeyRing(current, keys)
+	require.Nil(t, err)
+	return kr
+}
+
+func newTestSession(t *testing.T) *session.Session {
+	s, err := session.NewSession()
+	require.Nil(t, err)
+	s.AddAttribute("token", "secret")
+	return &s
+}
+
+func newStores(t *testing.T, keys *encrypt.KeyRing) (session.Store, session.Store) {
+	inner := memory.NewMemoryStore(context.Background(), logr.Discard(), "key", memory.WithSweepInterval(0))
+	return inner, encrypt.NewStore(inner, keys, logr.Discard(), "key")
+}
+
+func TestNewKeyRing(t *testing.T) {
+	_, err := encrypt.NewKeyRing("missing", map[string][]byte{"k1": make([]byte, 32)})
+	assert.NotNil(t, err)
+
+	_, err = encrypt.NewKeyRing("k1", map[string][]byte{"k1": make([]byte, 7)})
+	assert.NotNil(t, err)
+}
+
+func TestEncryptedAtRest(t *testing.T) {
+	ctx := context.Background()
+	inner, store := newStores(t, newKeyRing(t, "k1", "k1"))
+
+	s := newTestSession(t)
+	created, err := store.Create(ctx, s)
+	require.Nil(t, err)
+	assert.Equal(t, "secret", created.Data["token"])
+
+	raw, err := inner.Load(ctx, s.ID)
+	require.Nil(t, err)
+	require.Len(t, raw.Data, 1)
+	assert.Contains(t, raw.Data, encrypt.EncryptedDataKey)
+	assert.NotContains(t, raw.Data[encrypt.EncryptedDataKey], "secret")
+
+	loaded, err := store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "secret", loaded.Data["token"])
+}
+
+func TestEncryptedDataBoundToSession(t *testing.T) {
+	ctx := context.Background()
+	inner, store := newStores(t, newKeyRing(t, "k1", "k1"))
+
+	victim := newTestSession(t)
+	_, err := store.Create(ctx, victim)
+	require.Nil(t, err)
+	raw, err := inner.Load(ctx, victim.ID)
+	require.Nil(t, err)
+
+	attacker := newTestSession(t)
+	attacker.Data = raw.Data
+	_, err = inner.Create(ctx, attacker)
+	require.Nil(t, err)
+
+	_, err = store.Load(ctx, attacker.ID)
+	assert.ErrorIs(t, err, encrypt.ErrSessionMismatch)
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	inner, oldStore := newStores(t, newKeyRing(t, "k1", "k1"))
+	rotated := encrypt.NewStore(inner, newKeyRing(t, "k2", "k1", "k2"), logr.Discard(), "key")
+	newOnly := encrypt.NewStore(inner, newKeyRing(t, "k2", "k2"), logr.Discard(), "key")
+
+	s := newTestSession(t)
+	_, err := oldStore.Create(ctx, s)
+	require.Nil(t, err)
+
+	_, err = newOnly.Load(ctx, s.ID)
+	assert.ErrorIs(t, err, encrypt.ErrUnknownKey)
+
+	loaded, err := rotated.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "secret", loaded.Data["token"])
+
+	_, err = rotated.AddAttributes(ctx, s.ID, map[string]interface{}{"k": "v"})
+	require.Nil(t, err)
+
+	loaded, err = newOnly.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "secret", loaded.Data["token"])
+	assert.Equal(t, "v", loaded.Data["k"])
+}
+
+func TestPlaintextSessionIsEncryptedOnWrite(t *testing.T) {
+	ctx := context.Background()
+	inner, store := newStores(t, newKeyRing(t, "k1", "k1"))
+
+	s := newTestSession(t)
+	_, err := inner.Create(ctx, s)
+	require.Nil(t, err)
+
+	loaded, err := store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "secret", loaded.Data["token"])
+
+	_, err = store.IncrementAttribute(ctx, s.ID, "n", 1)
+	require.Nil(t, err)
+
+	raw, err := inner.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Contains(t, raw.Data, encrypt.EncryptedDataKey)
+	assert.NotContains(t, raw.Data, "token")
+}
+
+func TestRegenerateReencrypts(t *testing.T) {
+	ctx := context.Background()
+	inner, store := newStores(t, newKeyRing(t, "k1", "k1"))
+
+	s := newTestSession(t)
+	_, err := store.Create(ctx, s)
+	require.Nil(t, err)
+
+	newSID := newTestSession(t).ID
+	calls := 0
+	regenerated, err := store.Regenerate(ctx, s.ID, newSID, 0, func(s *session.Session) {
+		calls++
+		s.AddAttribute("promoted", true)
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "secret", regenerated.Data["token"])
+	assert.Equal(t, true, regenerated.Data["promoted"])
+
+	loaded, err := store.Load(ctx, newSID)
+	require.Nil(t, err)
+	assert.Equal(t, "secret", loaded.Data["token"])
+
+	raw, err := inner.Load(ctx, newSID)
+	require.Nil(t, err)
+	assert.Contains(t, raw.Data, encrypt.EncryptedDataKey)
+}
+
+func TestRegenerateUndecryptableSession(t *testing.T) {
+	ctx := context.Background()
+	inner, store := newStores(t, newKeyRing(t, "k1", "k1"))
+	other := encrypt.NewStore(inner, newKeyRing(t, "k2", "k2"), logr.Discard(), "key")
+
+	s := newTestSession(t)
+	_, err := store.Create(ctx, s)
+	require.Nil(t, err)
+
+	newSID := newTestSession(t).ID
+	_, err = other.Regenerate(ctx, s.ID, newSID, 0, nil)
+	assert.ErrorIs(t, err, encrypt.ErrUnknownKey)
+
+	loaded, err := store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "secret", loaded.Data["token"])
+
+	_, err = inner.Load(ctx, newSID)
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+}
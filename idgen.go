@@ -0,0 +1,99 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// idLen is number of random bytes in session ids generated by built-in generators
+const idLen = 32
+
+// IDGenerator generate new session ids and validate format of ids received from clients.
+// Generated ids should be unguessable and shouldn't contain SignatureSeparator
+type IDGenerator interface {
+	Generate() (string, error)
+	Validate(sid string) error
+}
+
+// IDValidator validate format of session ids without signature,
+// Service created by NewService implements it with its IDGenerator
+// and Middleware use it to validate ids read from the session cookie
+type IDValidator interface {
+	ValidateSessionID(sid string) error
+}
+
+// encodingIDGenerator generate random ids encoded with enc
+type encodingIDGenerator struct {
+	name string
+	enc  interface {
+		EncodeToString(src []byte) string
+		DecodeString(s string) ([]byte, error)
+		EncodedLen(n int) int
+	}
+}
+
+// NewBase32IDGenerator Create IDGenerator of 32 random bytes encoded as unpadded base32,
+// it's used by default
+func NewBase32IDGenerator() IDGenerator {
+	return encodingIDGenerator{name: "base32", enc: base32.StdEncoding.WithPadding(base32.NoPadding)}
+}
+
+// NewBase64URLIDGenerator Create IDGenerator of 32 random bytes encoded as unpadded base64url,
+// ids are shorter than base32 ones
+func NewBase64URLIDGenerator() IDGenerator {
+	return encodingIDGenerator{name: "base64url", enc: base64.RawURLEncoding}
+}
+
+func (g encodingIDGenerator) Generate() (string, error) {
+	id := make([]byte, idLen)
+	_, err := io.ReadFull(rand.Reader, id)
+	if err != nil {
+		return "", fmt.Errorf("error generating session id: %w", err)
+	}
+	return g.enc.EncodeToString(id), nil
+}
+
+func (g encodingIDGenerator) Validate(sid string) error {
+	if len(sid) != g.enc.EncodedLen(idLen) {
+		return errors.New("error validating session: wrong session id length")
+	}
+	_, err := g.enc.DecodeString(sid)
+	if err != nil {
+		return fmt.Errorf("error validating session: %w", err)
+	}
+	return nil
+}
+
+type prefixedIDGenerator struct {
+	prefix string
+	g      IDGenerator
+}
+
+// NewPrefixedIDGenerator Create IDGenerator adding prefix to ids generated by g,
+// e.g. "sess_<tenant>_", so ids can be recognized by log scrubbers and routing.
+// Prefix shouldn't contain SignatureSeparator and characters not allowed in cookie values
+func NewPrefixedIDGenerator(prefix string, g IDGenerator) IDGenerator {
+	return prefixedIDGenerator{prefix: prefix, g: g}
+}
+
+func (g prefixedIDGenerator) Generate() (string, error) {
+	sid, err := g.g.Generate()
+	if err != nil {
+		return "", err
+	}
+	return g.prefix + sid, nil
+}
+
+func (g prefixedIDGenerator) Validate(sid string) error {
+	if !strings.HasPrefix(sid, g.prefix) {
+		return errors.New("error validating session: wrong session id prefix")
+	}
+	return g.g.Validate(sid[len(g.prefix):])
+}
+
+var defaultIDGenerator = NewBase32IDGenerator()
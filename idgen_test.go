@@ -0,0 +1,120 @@
+package session_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/asstart/go-session"
+	smocks "github.com/asstart/go-session/mocks"
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seqIDGenerator generate deterministic ids for tests
+type seqIDGenerator struct {
+	n int
+}
+
+func (g *seqIDGenerator) Generate() (string, error) {
+	g.n++
+	return fmt.Sprintf("test-%d", g.n), nil
+}
+
+func (g *seqIDGenerator) Validate(sid string) error {
+	if !strings.HasPrefix(sid, "test-") {
+		return fmt.Errorf("wrong sid %q", sid)
+	}
+	return nil
+}
+
+func TestIDGenerators(t *testing.T) {
+	tt := []struct {
+		name    string
+		g       session.IDGenerator
+		expLen  int
+		invalid []string
+	}{
+		{"base32", session.NewBase32IDGenerator(), 52, []string{"", "a", strings.Repeat("!", 52), strings.Repeat("A", 53)}},
+		{"base64url", session.NewBase64URLIDGenerator(), 43, []string{"", "a", strings.Repeat("+", 43), strings.Repeat("A", 44)}},
+		{"prefixed", session.NewPrefixedIDGenerator("sess_tenant_", session.NewBase64URLIDGenerator()), 55, []string{"", strings.Repeat("A", 43), "sess_other_" + strings.Repeat("A", 43), "sess_tenant_a"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sid, err := tc.g.Generate()
+			require.Nil(t, err)
+			assert.Len(t, sid, tc.expLen)
+			assert.Nil(t, tc.g.Validate(sid))
+			assert.NotContains(t, sid, session.SignatureSeparator)
+
+			other, err := tc.g.Generate()
+			require.Nil(t, err)
+			assert.NotEqual(t, sid, other)
+
+			for _, invalid := range tc.invalid {
+				assert.NotNil(t, tc.g.Validate(invalid), invalid)
+			}
+		})
+	}
+}
+
+func TestServiceWithIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	store := smocks.NewMockStore(gomock.NewController(t))
+	svc := session.NewService(store, logr.Discard(), "key", session.WithIDGenerator(&seqIDGenerator{}))
+
+	gomock.InOrder(
+		store.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, s *session.Session) (*session.Session, error) {
+			assert.Equal(t, "test-1", s.ID)
+			return nil, session.ErrSessionExists
+		}),
+		store.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, s *session.Session) (*session.Session, error) {
+			assert.Equal(t, "test-2", s.ID)
+			cp := *s
+			return &cp, nil
+		}),
+		store.EXPECT().Regenerate(gomock.Any(), "test-2", "test-3", gomock.Any(), gomock.Any()).Return(&session.Session{ID: "test-3"}, nil),
+	)
+
+	s, err := svc.CreateAnonymSession(ctx, session.DefaultCookieConf(), session.DefaultSessionConf())
+	require.Nil(t, err)
+	assert.Equal(t, "test-2", s.ID)
+
+	s, err = svc.RegenerateSession(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "test-3", s.ID)
+}
+
+func TestCookieCodecWithValidator(t *testing.T) {
+	g := &seqIDGenerator{}
+	sg := newSigner(t, "key")
+
+	tt := []struct {
+		name   string
+		value  string
+		expSID string
+		expErr bool
+	}{
+		{"valid", "test-1", "test-1", false},
+		{"signed", sg.Sign("test-1"), sg.Sign("test-1"), false},
+		{"invalid", "other", "", true},
+		{"default format", testSID, "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: session.DefaultCookieName, Value: tc.value})
+
+			sid, err := session.NewCookieCodec(g.Validate).SID(r, session.DefaultCookieName)
+			assert.Equal(t, tc.expErr, err != nil)
+			assert.Equal(t, tc.expSID, sid)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package attrs contains attribute operations shared by stores
+// which implement atomic updates without support from the backend
+package attrs
+
+import "reflect"
+
+// AddNumber add delta to integer or float v, nil is considered to be 0,
+// integers are widened to int64 and floats to float64
+func AddNumber(v interface{}, delta int64) (interface{}, bool) {
+	if v == nil {
+		return delta, true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() + delta, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()) + delta, true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() + float64(delta), true
+	default:
+		return nil, false
+	}
+}
+
+// Append append values to slice or array v converted to []interface{},
+// nil is considered to be empty list. If maxLen > 0 only last maxLen elements are kept
+func Append(v interface{}, values []interface{}, maxLen int) ([]interface{}, bool) {
+	var list []interface{}
+	if v != nil {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, false
+		}
+
+		list = make([]interface{}, rv.Len(), rv.Len()+len(values))
+		for i := range list {
+			list[i] = rv.Index(i).Interface()
+		}
+	}
+
+	list = append(list, values...)
+	if maxLen > 0 && len(list) > maxLen {
+		list = list[len(list)-maxLen:]
+	}
+	if list == nil {
+		list = []interface{}{}
+	}
+
+	return list, true
+}
@@ -0,0 +1,596 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/internal/attrs"
+	"github.com/go-logr/logr"
+)
+
+const defaultSweepInterval = time.Minute
+
+type memoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*session.Session
+	// regenerated keep ids of sessions moved by Regenerate which stay active for grace
+	regenerated map[string]bool
+
+	Logger        logr.Logger
+	CtxReqIDKey   interface{}
+	SweepInterval time.Duration
+	Codec         session.Codec
+	Clock         session.Clock
+}
+
+// Option configures store created by NewMemoryStore
+type Option func(*memoryStore)
+
+// WithSweepInterval set how often expired sessions are removed from the store.
+// Zero or negative interval disables background sweeping
+func WithSweepInterval(d time.Duration) Option {
+	return func(ms *memoryStore) {
+		ms.SweepInterval = d
+	}
+}
+
+// WithCodec make the store keep attributes the way they're read back after encoding with c,
+// so it behaves the same as stores keeping attributes as bytes
+func WithCodec(c session.Codec) Option {
+	return func(ms *memoryStore) {
+		ms.Codec = c
+	}
+}
+
+// WithClock set clock used for timestamps and expiry checks, session.SystemClock is used by default
+func WithClock(c session.Clock) Option {
+	return func(ms *memoryStore) {
+		ms.Clock = c
+	}
+}
+
+/*
+NewMemoryStore Create implementation of session.Store keeping sessions in memory
+
+It's supposed to be used in tests and single-node deployments,
+sessions aren't shared between processes and are lost on restart.
+
+Sessions expired by idle or absolute timeout are removed in background
+every minute by default, sweeping stops when ctx is done.
+Invalidated sessions are kept until their timeouts pass, so they're reported as invalidated.
+
+reqIDKey is key to extract request id from the context
+*/
+func NewMemoryStore(ctx context.Context, l logr.Logger, reqIDKey interface{}, opts ...Option) session.Store {
+	ms := &memoryStore{
+		sessions:      make(map[string]*session.Session),
+		regenerated:   make(map[string]bool),
+		Logger:        l,
+		CtxReqIDKey:   reqIDKey,
+		SweepInterval: defaultSweepInterval,
+		Clock:         session.SystemClock(),
+	}
+	for _, o := range opts {
+		o(ms)
+	}
+
+	if ms.SweepInterval > 0 {
+		go ms.sweep(ctx)
+	}
+
+	return ms
+}
+
+func (ms *memoryStore) Save(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.Save() started", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Save() finished", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	now := ms.Clock.Now()
+
+	ns := copySession(s)
+	ns.CreatedAt = now
+	ns.LastAccessedAt = now
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ns.Version = 1
+	if old, ok := ms.sessions[ns.ID]; ok {
+		ns.CreatedAt = old.CreatedAt
+		ns.Version = old.Version + 1
+	}
+	err := ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Save() can't encode attributes", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+	delete(ms.regenerated, ns.ID)
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) Create(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.Create() started", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Create() finished", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	now := ms.Clock.Now()
+
+	ns := copySession(s)
+	ns.CreatedAt = now
+	ns.LastAccessedAt = now
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.sessions[ns.ID]; ok {
+		ms.Logger.V(0).Info("session.memory.Create() session already exists", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, session.ErrSessionExists
+	}
+	ns.Version = 1
+	err := ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Create() can't encode attributes", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) Load(ctx context.Context, sid string) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.Load() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Load() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	s, err := ms.live(sid)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Load() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s.LastAccessedAt = ms.Clock.Now()
+
+	return copySession(s), nil
+}
+
+func (ms *memoryStore) Peek(ctx context.Context, sid string) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.Peek() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Peek() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	s, err := ms.live(sid)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Peek() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return copySession(s), nil
+}
+
+func (ms *memoryStore) Exists(ctx context.Context, sid string) (bool, error) {
+	ms.Logger.V(0).Info("session.memory.Exists() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Exists() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	_, err := ms.live(sid)
+
+	return err == nil, nil
+}
+
+func (ms *memoryStore) AddAttributes(ctx context.Context, sid string, data map[string]interface{}) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.AddAttributes() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.AddAttributes() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.AddAttributes() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := ms.live(sid)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.AddAttributes() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	ns := copySession(s)
+	for k, v := range copyData(data) {
+		ns.AddAttribute(k, v)
+	}
+	ns.LastAccessedAt = ms.Clock.Now()
+	ns.Version++
+
+	err = ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.AddAttributes() can't encode attributes", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.RemoveAttributes() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.RemoveAttributes() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.RemoveAttributes() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := ms.live(sid)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.RemoveAttributes() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	ns := copySession(s)
+	for _, k := range keys {
+		ns.RemoveAttribute(k)
+	}
+	ns.LastAccessedAt = ms.Clock.Now()
+	ns.Version++
+
+	err = ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.RemoveAttributes() can't encode attributes", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.IncrementAttribute() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.IncrementAttribute() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.IncrementAttribute() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := ms.live(sid)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.IncrementAttribute() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	v, _ := s.GetAttribute(key)
+	n, ok := attrs.AddNumber(v, delta)
+	if !ok {
+		ms.Logger.V(0).Info("session.memory.IncrementAttribute() attribute isn't a number", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, session.ErrAttributeType
+	}
+
+	ns := copySession(s)
+	ns.AddAttribute(key, n)
+	ns.LastAccessedAt = ms.Clock.Now()
+	ns.Version++
+
+	err = ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.IncrementAttribute() can't encode attributes", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.AppendToAttribute() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.AppendToAttribute() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.AppendToAttribute() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := ms.live(sid)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.AppendToAttribute() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	v, _ := s.GetAttribute(key)
+	list, ok := attrs.Append(v, values, maxLen)
+	if !ok {
+		ms.Logger.V(0).Info("session.memory.AppendToAttribute() attribute isn't a list", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, session.ErrAttributeType
+	}
+
+	ns := copySession(s)
+	ns.AddAttribute(key, copyValue(list))
+	ns.LastAccessedAt = ms.Clock.Now()
+	ns.Version++
+
+	err = ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.AppendToAttribute() can't encode attributes", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) Invalidate(ctx context.Context, sid string) error {
+	ms.Logger.V(0).Info("session.memory.Invalidate() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Invalidate() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	s, ok := ms.sessions[sid]
+	if !ok {
+		ms.Logger.V(0).Info("session.memory.Invalidate() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return session.ErrSessionNotFound
+	}
+
+	// LastAccessedAt isn't bumped, so the session is swept when its original timeouts pass
+	s.Active = false
+	s.Version++
+
+	return nil
+}
+
+func (ms *memoryStore) Update(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.Update() started", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Update() finished", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	old, err := ms.live(s.ID)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Update() session not available", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	if old.Version != s.Version {
+		ms.Logger.V(0).Info("session.memory.Update() version conflict", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, session.ErrVersionConflict
+	}
+
+	ns := copySession(s)
+	ns.CreatedAt = old.CreatedAt
+	ns.LastAccessedAt = ms.Clock.Now()
+	ns.Version = old.Version + 1
+	err = ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Update() can't encode attributes", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*session.Session)) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.Regenerate() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.Regenerate() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	old, err := ms.live(sid)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Regenerate() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	if ms.regenerated[sid] {
+		ms.Logger.V(0).Info("session.memory.Regenerate() session already regenerated", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, session.ErrVersionConflict
+	}
+
+	if _, ok := ms.sessions[newSID]; ok {
+		ms.Logger.V(0).Info("session.memory.Regenerate() session already exists", session.LogKeySID, newSID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, session.ErrSessionExists
+	}
+
+	now := ms.Clock.Now()
+
+	ns := copySession(old)
+	if modify != nil {
+		modify(ns)
+	}
+	ns.ID = newSID
+	ns.Active = true
+	ns.CreatedAt = old.CreatedAt
+	ns.LastAccessedAt = now
+	ns.Version = old.Version + 1
+
+	err = ms.commit(ns)
+	if err != nil {
+		ms.Logger.V(0).Info("session.memory.Regenerate() can't encode attributes", session.LogKeySID, newSID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	old.Version++
+	if grace > 0 {
+		abs := now.Add(grace).Sub(old.CreatedAt)
+		if abs < old.AbsTimeout {
+			old.AbsTimeout = abs
+		}
+		ms.regenerated[sid] = true
+	} else {
+		old.Active = false
+	}
+
+	return copySession(ns), nil
+}
+
+func (ms *memoryStore) ListUserSessions(ctx context.Context, uid string) ([]*session.Session, error) {
+	ms.Logger.V(0).Info("session.memory.ListUserSessions() started", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.ListUserSessions() finished", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	now := ms.Clock.Now()
+	sessions := []*session.Session{}
+	for _, s := range ms.sessions {
+		if s.UID == uid && !s.IsExpiredAt(now) {
+			sessions = append(sessions, copySession(s))
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastAccessedAt.After(sessions[j].LastAccessedAt)
+	})
+
+	return sessions, nil
+}
+
+func (ms *memoryStore) InvalidateUserSessions(ctx context.Context, uid string, exceptSIDs ...string) error {
+	ms.Logger.V(0).Info("session.memory.InvalidateUserSessions() started", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.memory.InvalidateUserSessions() finished", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	except := make(map[string]bool, len(exceptSIDs))
+	for _, sid := range exceptSIDs {
+		except[sid] = true
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for sid, s := range ms.sessions {
+		if s.UID == uid && s.Active && !except[sid] {
+			s.Active = false
+			s.Version++
+		}
+	}
+
+	return nil
+}
+
+// live return stored session if it isn't expired or invalidated,
+// caller should hold the lock
+func (ms *memoryStore) live(sid string) (*session.Session, error) {
+	s, ok := ms.sessions[sid]
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
+	err := s.CheckExpiredAt(ms.Clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// commit keep ns in the store, attributes are replaced with their decoded copy if codec is set,
+// caller should hold the lock
+func (ms *memoryStore) commit(ns *session.Session) error {
+	if ms.Codec != nil && ns.Data != nil {
+		b, err := ms.Codec.Marshal(ns.Data)
+		if err != nil {
+			return err
+		}
+		ns.Data, err = ms.Codec.Unmarshal(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	ms.sessions[ns.ID] = ns
+	return nil
+}
+
+func (ms *memoryStore) sweep(ctx context.Context) {
+	t := time.NewTicker(ms.SweepInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			ms.removeExpired()
+		}
+	}
+}
+
+func (ms *memoryStore) removeExpired() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.Clock.Now()
+	removed := 0
+	for sid, s := range ms.sessions {
+		if timedOut(s, now) {
+			delete(ms.sessions, sid)
+			delete(ms.regenerated, sid)
+			removed++
+		}
+	}
+
+	ms.Logger.V(0).Info("session.memory.sweep() finished", "session.removed", removed)
+}
+
+// timedOut check if session is expired by idle or absolute timeout,
+// invalidated sessions are kept until then to report ErrSessionInvalidated
+// the same way as stores removing documents by TTL
+func timedOut(s *session.Session, now time.Time) bool {
+	return s.LastAccessedAt.Add(s.IdleTimeout).Before(now) || s.CreatedAt.Add(s.AbsTimeout).Before(now)
+}
+
+func copySession(s *session.Session) *session.Session {
+	cp := *s
+	cp.Data = copyData(s.Data)
+	return &cp
+}
+
+// copyData make a deep copy of maps and slices of interface{},
+// so callers can't modify data kept in the store
+func copyData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	cp := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		cp[k] = copyValue(v)
+	}
+	return cp
+}
+
+func copyValue(v interface{}) interface{} {
+	switch cv := v.(type) {
+	case map[string]interface{}:
+		return copyData(cv)
+	case []interface{}:
+		cs := make([]interface{}, len(cv))
+		for i, e := range cv {
+			cs[i] = copyValue(e)
+		}
+		return cs
+	default:
+		return v
+	}
+}
@@ -0,0 +1,149 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/codec"
+	"github.com/asstart/go-session/memory"
+	"github.com/asstart/go-session/storetest"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSession(t *testing.T) *session.Session {
+	s, err := session.NewSession()
+	assert.Nil(t, err)
+	return &s
+}
+
+func TestMemoryStoreSuite(t *testing.T) {
+	storetest.RunStoreSuite(t, func() session.Store {
+		return memory.NewMemoryStore(context.Background(), logr.Discard(), "key")
+	})
+}
+
+func TestMemoryStoreSuiteWithClock(t *testing.T) {
+	clock := storetest.NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	storetest.RunStoreSuiteWithClock(t, clock, func() session.Store {
+		return memory.NewMemoryStore(context.Background(), logr.Discard(), "key", memory.WithSweepInterval(0), memory.WithClock(clock))
+	})
+}
+
+func TestMemoryStoreSuiteWithCodec(t *testing.T) {
+	codecs := []struct {
+		name  string
+		codec session.Codec
+	}{
+		{"json", codec.NewJSON()},
+		{"gob", codec.NewGob()},
+		{"bson", codec.NewBSON()},
+	}
+
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			storetest.RunStoreSuite(t, func() session.Store {
+				return memory.NewMemoryStore(context.Background(), logr.Discard(), "key", memory.WithCodec(c.codec))
+			})
+		})
+	}
+}
+
+func TestLoadedSessionIsCopy(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore(ctx, logr.Discard(), "key")
+
+	s := newTestSession(t)
+	s.AddAttribute("k1", map[string]interface{}{"nested": "v1"})
+	_, err := store.Save(ctx, s)
+	assert.Nil(t, err)
+
+	s.AddAttribute("k2", "v2")
+
+	loaded, err := store.Load(ctx, s.ID)
+	assert.Nil(t, err)
+	loaded.Data["k1"].(map[string]interface{})["nested"] = "changed"
+
+	loaded, err = store.Load(ctx, s.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": map[string]interface{}{"nested": "v1"}}, loaded.Data)
+}
+
+func TestSweepExpiredSessions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := memory.NewMemoryStore(ctx, logr.Discard(), "key", memory.WithSweepInterval(10*time.Millisecond))
+
+	expired := newTestSession(t)
+	expired.IdleTimeout = time.Millisecond
+	_, err := store.Save(ctx, expired)
+	assert.Nil(t, err)
+
+	alive := newTestSession(t)
+	_, err = store.Save(ctx, alive)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := store.Load(ctx, expired.ID)
+		return err == session.ErrSessionNotFound
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = store.Load(ctx, alive.ID)
+	assert.Nil(t, err)
+}
+
+func TestExpiryWithClock(t *testing.T) {
+	ctx := context.Background()
+	clock := storetest.NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := memory.NewMemoryStore(ctx, logr.Discard(), "key", memory.WithSweepInterval(0), memory.WithClock(clock))
+
+	s := newTestSession(t)
+	s.IdleTimeout = time.Hour
+	s.AbsTimeout = 90 * time.Minute
+	saved, err := store.Save(ctx, s)
+	assert.Nil(t, err)
+	assert.Equal(t, clock.Now(), saved.CreatedAt)
+
+	clock.Advance(time.Hour)
+	loaded, err := store.Load(ctx, s.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, clock.Now(), loaded.LastAccessedAt)
+
+	clock.Advance(time.Hour)
+	_, err = store.Load(ctx, s.ID)
+	assert.Equal(t, session.ErrSessionExpired, err)
+
+	sessions, err := store.ListUserSessions(ctx, "")
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestSweepKeepsInvalidatedSessions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := storetest.NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := memory.NewMemoryStore(ctx, logr.Discard(), "key", memory.WithSweepInterval(time.Millisecond), memory.WithClock(clock))
+
+	s := newTestSession(t)
+	s.IdleTimeout = time.Hour
+	_, err := store.Save(ctx, s)
+	assert.Nil(t, err)
+
+	clock.Advance(30 * time.Minute)
+	assert.Nil(t, store.Invalidate(ctx, s.ID))
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = store.Load(ctx, s.ID)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+
+	// invalidation doesn't extend retention, the session is swept when its idle timeout passes
+	clock.Advance(31 * time.Minute)
+	assert.Eventually(t, func() bool {
+		_, err := store.Load(ctx, s.ID)
+		return err == session.ErrSessionNotFound
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,258 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// DefaultCookieName is name of the session cookie used by Middleware
+const DefaultCookieName = "sid"
+
+type middlewareCtxKey struct{}
+
+// sessionHolder keeps session bound to the request,
+// it's shared between Middleware and handlers to let handlers replace the session
+type sessionHolder struct {
+	s *Session
+	// committed is set when the session cookie is written to the response
+	committed bool
+}
+
+type middlewareConf struct {
+	cookieName   string
+	createAnonym bool
+	cookieConf   CookieConf
+	sessionConf  Conf
+	errorHandler func(w http.ResponseWriter, r *http.Request, err error)
+	cookieCodec  CookieCodec
+	clock        Clock
+}
+
+// MiddlewareOption configures Middleware
+type MiddlewareOption func(*middlewareConf)
+
+// WithCookieName set name of the session cookie, DefaultCookieName is used by default
+func WithCookieName(name string) MiddlewareOption {
+	return func(mc *middlewareConf) {
+		mc.cookieName = name
+	}
+}
+
+// WithAnonymSession make Middleware create anonym session
+// if request doesn't have a valid one
+func WithAnonymSession(cc CookieConf, sc Conf) MiddlewareOption {
+	return func(mc *middlewareConf) {
+		mc.createAnonym = true
+		mc.cookieConf = cc
+		mc.sessionConf = sc
+	}
+}
+
+// WithErrorHandler set handler called when session can't be loaded or created
+// because of unexpected Service error, by default 500 Internal Server Error is returned
+func WithErrorHandler(h func(w http.ResponseWriter, r *http.Request, err error)) MiddlewareOption {
+	return func(mc *middlewareConf) {
+		mc.errorHandler = h
+	}
+}
+
+// WithCookieCodec set how session id is read from the request and session cookie is written,
+// by default session id is kept in a single cookie and validated with IDGenerator of the Service
+// (see IDValidator), DefaultCookieCodec is used if Service doesn't implement IDValidator
+func WithCookieCodec(c CookieCodec) MiddlewareOption {
+	return func(mc *middlewareConf) {
+		mc.cookieCodec = c
+	}
+}
+
+// WithClock set clock used to check expiry of loaded sessions, SystemClock is used by default
+func WithClock(c Clock) MiddlewareOption {
+	return func(mc *middlewareConf) {
+		mc.clock = c
+	}
+}
+
+/*
+Middleware load session by the cookie for every request and put it to the request context,
+session can be retrieved with FromContext.
+
+Expired, invalidated or unknown sessions aren't put to the context,
+they are replaced with a new anonym one if WithAnonymSession is used,
+otherwise the cookie is removed.
+
+Set-Cookie header is written before the response based on Session.Opts
+of the session bound to the request at that moment, see ReplaceSession.
+*/
+func Middleware(svc Service, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	mc := middlewareConf{
+		cookieName:   DefaultCookieName,
+		cookieConf:   DefaultCookieConf(),
+		sessionConf:  DefaultSessionConf(),
+		errorHandler: defaultErrorHandler,
+		clock:        SystemClock(),
+	}
+	for _, o := range opts {
+		o(&mc)
+	}
+	if mc.cookieCodec == nil {
+		mc.cookieCodec = DefaultCookieCodec()
+		if v, ok := svc.(IDValidator); ok {
+			mc.cookieCodec = NewCookieCodec(v.ValidateSessionID)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			s, hadCookie, err := mc.loadSession(ctx, svc, r)
+			if err != nil {
+				mc.errorHandler(w, r, err)
+				return
+			}
+
+			if s == nil && mc.createAnonym {
+				s, err = svc.CreateAnonymSession(ctx, mc.cookieConf, mc.sessionConf)
+				if err != nil {
+					mc.errorHandler(w, r, err)
+					return
+				}
+			}
+
+			h := &sessionHolder{s: s}
+			cw := &cookieWriter{
+				ResponseWriter: w,
+				r:              r,
+				conf:           &mc,
+				holder:         h,
+				hadCookie:      hadCookie,
+			}
+
+			next.ServeHTTP(cw, r.WithContext(context.WithValue(ctx, middlewareCtxKey{}, h)))
+
+			cw.writeCookie()
+		})
+	}
+}
+
+// loadSession return live session from the request cookie,
+// nil session means there is no live session for the request
+func (mc *middlewareConf) loadSession(ctx context.Context, svc Service, r *http.Request) (*Session, bool, error) {
+	sid, err := mc.cookieCodec.SID(r, mc.cookieName)
+	if errors.Is(err, ErrNoSessionCookie) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, nil
+	}
+
+	s, err := svc.LoadSession(ctx, sid)
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired) || errors.Is(err, ErrSessionInvalidated) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+
+	if s.IsExpiredAt(mc.clock.Now()) {
+		return nil, true, nil
+	}
+
+	return s, true, nil
+}
+
+// FromContext return session bound to the request by Middleware
+func FromContext(ctx context.Context) (*Session, bool) {
+	h, ok := ctx.Value(middlewareCtxKey{}).(*sessionHolder)
+	if !ok || h.s == nil {
+		return nil, false
+	}
+	return h.s, true
+}
+
+// ReplaceSession bind another session to the request handled by Middleware,
+// e.g. after login or session id regeneration, cookie will be written for the new session.
+// nil session removes the session cookie, e.g. after logout.
+// It returns false if ctx doesn't belong to a request handled by Middleware
+// or response headers are already written
+func ReplaceSession(ctx context.Context, s *Session) bool {
+	h, ok := ctx.Value(middlewareCtxKey{}).(*sessionHolder)
+	if !ok || h.committed {
+		return false
+	}
+	h.s = s
+	return true
+}
+
+func defaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// cookieWriter write session cookie right before response headers
+type cookieWriter struct {
+	http.ResponseWriter
+	r         *http.Request
+	conf      *middlewareConf
+	holder    *sessionHolder
+	hadCookie bool
+}
+
+func (cw *cookieWriter) WriteHeader(code int) {
+	cw.writeCookie()
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cookieWriter) Write(b []byte) (int, error) {
+	cw.writeCookie()
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush write the session cookie and flush the response if original http.ResponseWriter
+// implements http.Flusher, so streaming handlers work behind Middleware
+func (cw *cookieWriter) Flush() {
+	cw.writeCookie()
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack let handlers take over the connection (e.g. WebSocket upgrade)
+// if original http.ResponseWriter implements http.Hijacker,
+// the session cookie isn't written after the connection is hijacked
+func (cw *cookieWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	cw.holder.committed = true
+	return conn, rw, nil
+}
+
+// Unwrap return original http.ResponseWriter, it's used by http.ResponseController
+func (cw *cookieWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *cookieWriter) writeCookie() {
+	if cw.holder.committed {
+		return
+	}
+	cw.holder.committed = true
+
+	if cw.holder.s != nil {
+		cw.conf.cookieCodec.SetCookie(cw.ResponseWriter, cw.r, cw.holder.s.Cookie(cw.conf.cookieName))
+		return
+	}
+
+	if cw.hadCookie {
+		cw.conf.cookieCodec.SetCookie(cw.ResponseWriter, cw.r, ExpiredCookie(cw.conf.cookieName, cw.conf.cookieConf))
+	}
+}
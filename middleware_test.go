@@ -0,0 +1,371 @@
+package session_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/memory"
+	smocks "github.com/asstart/go-session/mocks"
+	"github.com/asstart/go-session/storetest"
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func liveSession(t *testing.T) *session.Session {
+	s, err := session.NewSession()
+	assert.Nil(t, err)
+	s.CreatedAt = time.Now()
+	s.LastAccessedAt = time.Now()
+	return &s
+}
+
+func requestWithCookie(sid string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if sid != "" {
+		r.AddCookie(&http.Cookie{Name: session.DefaultCookieName, Value: sid})
+	}
+	return r
+}
+
+func responseCookie(t *testing.T, rr *httptest.ResponseRecorder) *http.Cookie {
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 {
+		return nil
+	}
+	assert.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+func TestMiddlewareNoCookie(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	var found bool
+	h := session.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, found = session.FromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(""))
+
+	assert.False(t, found)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, responseCookie(t, rr))
+}
+
+func TestMiddlewareCreateAnonymSession(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	cc := session.DefaultCookieConf()
+	sc := session.DefaultSessionConf()
+	created := liveSession(t)
+
+	svc.EXPECT().CreateAnonymSession(gomock.Any(), cc, sc).Return(created, nil)
+
+	var got *session.Session
+	h := session.Middleware(svc, session.WithAnonymSession(cc, sc))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = session.FromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(""))
+
+	assert.Same(t, created, got)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	c := responseCookie(t, rr)
+	assert.NotNil(t, c)
+	assert.Equal(t, session.DefaultCookieName, c.Name)
+	assert.Equal(t, created.ID, c.Value)
+	assert.Equal(t, cc.MaxAge, c.MaxAge)
+	assert.Equal(t, cc.Path, c.Path)
+	assert.True(t, c.Secure)
+	assert.True(t, c.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, c.SameSite)
+}
+
+func TestMiddlewareLoadSession(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	loaded := liveSession(t)
+	svc.EXPECT().LoadSession(gomock.Any(), loaded.ID).Return(loaded, nil)
+
+	var got *session.Session
+	h := session.Middleware(svc, session.WithCookieName(session.DefaultCookieName))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = session.FromContext(r.Context())
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(loaded.ID))
+
+	assert.Same(t, loaded, got)
+	c := responseCookie(t, rr)
+	assert.NotNil(t, c)
+	assert.Equal(t, loaded.ID, c.Value)
+}
+
+func TestMiddlewareStaleSession(t *testing.T) {
+	expired := liveSession(t)
+	expired.IdleTimeout = 0
+	inactive := liveSession(t)
+	inactive.Active = false
+
+	tt := []struct {
+		name    string
+		session *session.Session
+		err     error
+	}{
+		{"session not found", nil, session.ErrSessionNotFound},
+		{"session expired error", nil, session.ErrSessionExpired},
+		{"session invalidated error", nil, session.ErrSessionInvalidated},
+		{"expired session", expired, nil},
+		{"inactive session", inactive, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name+" without anonym session", func(t *testing.T) {
+			svc := smocks.NewMockService(gomock.NewController(t))
+			sid := liveSession(t).ID
+			svc.EXPECT().LoadSession(gomock.Any(), sid).Return(tc.session, tc.err)
+
+			var found bool
+			h := session.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, found = session.FromContext(r.Context())
+			}))
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, requestWithCookie(sid))
+
+			assert.False(t, found)
+			c := responseCookie(t, rr)
+			assert.NotNil(t, c)
+			assert.Equal(t, "", c.Value)
+			assert.True(t, c.MaxAge < 0)
+		})
+
+		t.Run(tc.name+" with anonym session", func(t *testing.T) {
+			svc := smocks.NewMockService(gomock.NewController(t))
+			sid := liveSession(t).ID
+			created := liveSession(t)
+			svc.EXPECT().LoadSession(gomock.Any(), sid).Return(tc.session, tc.err)
+			svc.EXPECT().CreateAnonymSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(created, nil)
+
+			var got *session.Session
+			h := session.Middleware(svc, session.WithAnonymSession(session.DefaultCookieConf(), session.DefaultSessionConf()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = session.FromContext(r.Context())
+			}))
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, requestWithCookie(sid))
+
+			assert.Same(t, created, got)
+			c := responseCookie(t, rr)
+			assert.NotNil(t, c)
+			assert.Equal(t, created.ID, c.Value)
+		})
+	}
+}
+
+func TestMiddlewareInvalidSessionID(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	h := session.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie("garbage"))
+
+	c := responseCookie(t, rr)
+	assert.NotNil(t, c)
+	assert.True(t, c.MaxAge < 0)
+}
+
+func TestMiddlewareLoadError(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	sid := liveSession(t).ID
+	svc.EXPECT().LoadSession(gomock.Any(), sid).Return(nil, errors.New("some err"))
+
+	called := false
+	h := session.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(sid))
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestMiddlewareCustomErrorHandler(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	retErr := errors.New("some err")
+	svc.EXPECT().CreateAnonymSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, retErr)
+
+	var handled error
+	h := session.Middleware(svc,
+		session.WithAnonymSession(session.DefaultCookieConf(), session.DefaultSessionConf()),
+		session.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(""))
+
+	assert.Equal(t, retErr, handled)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+func TestMiddlewareReplaceSession(t *testing.T) {
+	loaded := liveSession(t)
+	replaced := liveSession(t)
+
+	tt := []struct {
+		name     string
+		replace  *session.Session
+		expValue string
+	}{
+		{"replace with new session", replaced, replaced.ID},
+		{"remove session", nil, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := smocks.NewMockService(gomock.NewController(t))
+			svc.EXPECT().LoadSession(gomock.Any(), loaded.ID).Return(loaded, nil)
+
+			h := session.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.True(t, session.ReplaceSession(r.Context(), tc.replace))
+				_, _ = w.Write([]byte("ok"))
+				assert.False(t, session.ReplaceSession(r.Context(), loaded))
+			}))
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, requestWithCookie(loaded.ID))
+
+			c := responseCookie(t, rr)
+			assert.NotNil(t, c)
+			assert.Equal(t, tc.expValue, c.Value)
+		})
+	}
+}
+
+func TestMiddlewareClock(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	loaded := liveSession(t)
+	svc.EXPECT().LoadSession(gomock.Any(), loaded.ID).Return(loaded, nil)
+
+	clock := storetest.NewFakeClock(loaded.LastAccessedAt.Add(loaded.IdleTimeout + time.Second))
+
+	var found bool
+	h := session.Middleware(svc, session.WithClock(clock))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, found = session.FromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(loaded.ID))
+
+	assert.False(t, found)
+	c := responseCookie(t, rr)
+	assert.NotNil(t, c)
+	assert.True(t, c.MaxAge < 0)
+}
+
+func TestMiddlewareCustomIDGenerator(t *testing.T) {
+	store := memory.NewMemoryStore(context.Background(), logr.Discard(), "key", memory.WithSweepInterval(0))
+	gen := session.NewPrefixedIDGenerator("sess_", session.NewBase64URLIDGenerator())
+	svc := session.NewService(store, logr.Discard(), "key", session.WithIDGenerator(gen))
+
+	var sid string
+	h := session.Middleware(svc, session.WithAnonymSession(session.DefaultCookieConf(), session.DefaultSessionConf()))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, ok := session.FromContext(r.Context())
+			assert.True(t, ok)
+			sid = s.ID
+		}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	created := responseCookie(t, rr)
+	assert.NotNil(t, created)
+	assert.Nil(t, gen.Validate(created.Value))
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(created.Value))
+	assert.Equal(t, created.Value, sid)
+	if c := responseCookie(t, rr); c != nil {
+		assert.Equal(t, created.Value, c.Value)
+	}
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	loaded := liveSession(t)
+	svc.EXPECT().LoadSession(gomock.Any(), loaded.ID).Return(loaded, nil)
+
+	h := session.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		f.Flush()
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(loaded.ID))
+
+	assert.True(t, rr.Flushed)
+	c := responseCookie(t, rr)
+	assert.NotNil(t, c)
+	assert.Equal(t, loaded.ID, c.Value)
+}
+
+// hijackRecorder is httptest.ResponseRecorder supporting http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (hr *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hr.hijacked = true
+	c, _ := net.Pipe()
+	return c, bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c)), nil
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	svc := smocks.NewMockService(gomock.NewController(t))
+
+	loaded := liveSession(t)
+	svc.EXPECT().LoadSession(gomock.Any(), loaded.ID).Return(loaded, nil).Times(2)
+
+	h := session.Middleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		assert.True(t, ok)
+		conn, _, err := hj.Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+
+	hr := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(hr, requestWithCookie(loaded.ID))
+	assert.True(t, hr.hijacked)
+	assert.Nil(t, responseCookie(t, hr.ResponseRecorder))
+
+	// original writer can't be hijacked
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, requestWithCookie(loaded.ID))
+	assert.NotNil(t, responseCookie(t, rr))
+}
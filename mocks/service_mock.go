@@ -55,6 +55,21 @@ func (mr *MockServiceMockRecorder) AddAttributes(ctx, sid interface{}, keyAndVal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAttributes", reflect.TypeOf((*MockService)(nil).AddAttributes), varargs...)
 }
 
+// AppendToAttribute mocks base method.
+func (m *MockService) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendToAttribute", ctx, sid, key, values, maxLen)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppendToAttribute indicates an expected call of AppendToAttribute.
+func (mr *MockServiceMockRecorder) AppendToAttribute(ctx, sid, key, values, maxLen interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendToAttribute", reflect.TypeOf((*MockService)(nil).AppendToAttribute), ctx, sid, key, values, maxLen)
+}
+
 // CreateAnonymSession mocks base method.
 func (m *MockService) CreateAnonymSession(ctx context.Context, cc session.CookieConf, sc session.Conf, keyAndValues ...interface{}) (*session.Session, error) {
 	m.ctrl.T.Helper()
@@ -95,6 +110,21 @@ func (mr *MockServiceMockRecorder) CreateUserSession(ctx, uid, cc, sc interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserSession", reflect.TypeOf((*MockService)(nil).CreateUserSession), varargs...)
 }
 
+// IncrementAttribute mocks base method.
+func (m *MockService) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementAttribute", ctx, sid, key, delta)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementAttribute indicates an expected call of IncrementAttribute.
+func (mr *MockServiceMockRecorder) IncrementAttribute(ctx, sid, key, delta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementAttribute", reflect.TypeOf((*MockService)(nil).IncrementAttribute), ctx, sid, key, delta)
+}
+
 // InvalidateSession mocks base method.
 func (m *MockService) InvalidateSession(ctx context.Context, sid string) error {
 	m.ctrl.T.Helper()
@@ -109,6 +139,40 @@ func (mr *MockServiceMockRecorder) InvalidateSession(ctx, sid interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateSession", reflect.TypeOf((*MockService)(nil).InvalidateSession), ctx, sid)
 }
 
+// InvalidateUserSessions mocks base method.
+func (m *MockService) InvalidateUserSessions(ctx context.Context, uid string, exceptSID ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, uid}
+	for _, a := range exceptSID {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "InvalidateUserSessions", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidateUserSessions indicates an expected call of InvalidateUserSessions.
+func (mr *MockServiceMockRecorder) InvalidateUserSessions(ctx, uid interface{}, exceptSID ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, uid}, exceptSID...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateUserSessions", reflect.TypeOf((*MockService)(nil).InvalidateUserSessions), varargs...)
+}
+
+// ListUserSessions mocks base method.
+func (m *MockService) ListUserSessions(ctx context.Context, uid string) ([]*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserSessions", ctx, uid)
+	ret0, _ := ret[0].([]*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserSessions indicates an expected call of ListUserSessions.
+func (mr *MockServiceMockRecorder) ListUserSessions(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserSessions", reflect.TypeOf((*MockService)(nil).ListUserSessions), ctx, uid)
+}
+
 // LoadSession mocks base method.
 func (m *MockService) LoadSession(ctx context.Context, sid string) (*session.Session, error) {
 	m.ctrl.T.Helper()
@@ -124,6 +188,71 @@ func (mr *MockServiceMockRecorder) LoadSession(ctx, sid interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSession", reflect.TypeOf((*MockService)(nil).LoadSession), ctx, sid)
 }
 
+// ModifySession mocks base method.
+func (m *MockService) ModifySession(ctx context.Context, sid string, fn func(*session.Session) error) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModifySession", ctx, sid, fn)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ModifySession indicates an expected call of ModifySession.
+func (mr *MockServiceMockRecorder) ModifySession(ctx, sid, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModifySession", reflect.TypeOf((*MockService)(nil).ModifySession), ctx, sid, fn)
+}
+
+// PeekSession mocks base method.
+func (m *MockService) PeekSession(ctx context.Context, sid string) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PeekSession", ctx, sid)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PeekSession indicates an expected call of PeekSession.
+func (mr *MockServiceMockRecorder) PeekSession(ctx, sid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PeekSession", reflect.TypeOf((*MockService)(nil).PeekSession), ctx, sid)
+}
+
+// PromoteToUser mocks base method.
+func (m *MockService) PromoteToUser(ctx context.Context, sid, uid string, opts ...session.PromoteOption) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, sid, uid}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PromoteToUser", varargs...)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PromoteToUser indicates an expected call of PromoteToUser.
+func (mr *MockServiceMockRecorder) PromoteToUser(ctx, sid, uid interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, sid, uid}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PromoteToUser", reflect.TypeOf((*MockService)(nil).PromoteToUser), varargs...)
+}
+
+// RegenerateSession mocks base method.
+func (m *MockService) RegenerateSession(ctx context.Context, sid string) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegenerateSession", ctx, sid)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegenerateSession indicates an expected call of RegenerateSession.
+func (mr *MockServiceMockRecorder) RegenerateSession(ctx, sid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegenerateSession", reflect.TypeOf((*MockService)(nil).RegenerateSession), ctx, sid)
+}
+
 // RemoveAttributes mocks base method.
 func (m *MockService) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*session.Session, error) {
 	m.ctrl.T.Helper()
@@ -143,3 +272,33 @@ func (mr *MockServiceMockRecorder) RemoveAttributes(ctx, sid interface{}, keys .
 	varargs := append([]interface{}{ctx, sid}, keys...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAttributes", reflect.TypeOf((*MockService)(nil).RemoveAttributes), varargs...)
 }
+
+// SessionExists mocks base method.
+func (m *MockService) SessionExists(ctx context.Context, sid string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SessionExists", ctx, sid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SessionExists indicates an expected call of SessionExists.
+func (mr *MockServiceMockRecorder) SessionExists(ctx, sid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SessionExists", reflect.TypeOf((*MockService)(nil).SessionExists), ctx, sid)
+}
+
+// UpdateSession mocks base method.
+func (m *MockService) UpdateSession(ctx context.Context, s *session.Session) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSession", ctx, s)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSession indicates an expected call of UpdateSession.
+func (mr *MockServiceMockRecorder) UpdateSession(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSession", reflect.TypeOf((*MockService)(nil).UpdateSession), ctx, s)
+}
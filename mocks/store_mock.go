@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	session "github.com/asstart/go-session"
 	gomock "github.com/golang/mock/gomock"
@@ -50,6 +51,66 @@ func (mr *MockStoreMockRecorder) AddAttributes(ctx, sid, data interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAttributes", reflect.TypeOf((*MockStore)(nil).AddAttributes), ctx, sid, data)
 }
 
+// AppendToAttribute mocks base method.
+func (m *MockStore) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendToAttribute", ctx, sid, key, values, maxLen)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppendToAttribute indicates an expected call of AppendToAttribute.
+func (mr *MockStoreMockRecorder) AppendToAttribute(ctx, sid, key, values, maxLen interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendToAttribute", reflect.TypeOf((*MockStore)(nil).AppendToAttribute), ctx, sid, key, values, maxLen)
+}
+
+// Create mocks base method.
+func (m *MockStore) Create(ctx context.Context, s *session.Session) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, s)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockStoreMockRecorder) Create(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockStore)(nil).Create), ctx, s)
+}
+
+// Exists mocks base method.
+func (m *MockStore) Exists(ctx context.Context, sid string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", ctx, sid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockStoreMockRecorder) Exists(ctx, sid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockStore)(nil).Exists), ctx, sid)
+}
+
+// IncrementAttribute mocks base method.
+func (m *MockStore) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementAttribute", ctx, sid, key, delta)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementAttribute indicates an expected call of IncrementAttribute.
+func (mr *MockStoreMockRecorder) IncrementAttribute(ctx, sid, key, delta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementAttribute", reflect.TypeOf((*MockStore)(nil).IncrementAttribute), ctx, sid, key, delta)
+}
+
 // Invalidate mocks base method.
 func (m *MockStore) Invalidate(ctx context.Context, sid string) error {
 	m.ctrl.T.Helper()
@@ -64,6 +125,40 @@ func (mr *MockStoreMockRecorder) Invalidate(ctx, sid interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockStore)(nil).Invalidate), ctx, sid)
 }
 
+// InvalidateUserSessions mocks base method.
+func (m *MockStore) InvalidateUserSessions(ctx context.Context, uid string, exceptSIDs ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, uid}
+	for _, a := range exceptSIDs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "InvalidateUserSessions", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidateUserSessions indicates an expected call of InvalidateUserSessions.
+func (mr *MockStoreMockRecorder) InvalidateUserSessions(ctx, uid interface{}, exceptSIDs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, uid}, exceptSIDs...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateUserSessions", reflect.TypeOf((*MockStore)(nil).InvalidateUserSessions), varargs...)
+}
+
+// ListUserSessions mocks base method.
+func (m *MockStore) ListUserSessions(ctx context.Context, uid string) ([]*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserSessions", ctx, uid)
+	ret0, _ := ret[0].([]*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserSessions indicates an expected call of ListUserSessions.
+func (mr *MockStoreMockRecorder) ListUserSessions(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserSessions", reflect.TypeOf((*MockStore)(nil).ListUserSessions), ctx, uid)
+}
+
 // Load mocks base method.
 func (m *MockStore) Load(ctx context.Context, sid string) (*session.Session, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +174,36 @@ func (mr *MockStoreMockRecorder) Load(ctx, sid interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockStore)(nil).Load), ctx, sid)
 }
 
+// Peek mocks base method.
+func (m *MockStore) Peek(ctx context.Context, sid string) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek", ctx, sid)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockStoreMockRecorder) Peek(ctx, sid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockStore)(nil).Peek), ctx, sid)
+}
+
+// Regenerate mocks base method.
+func (m *MockStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*session.Session)) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Regenerate", ctx, sid, newSID, grace, modify)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Regenerate indicates an expected call of Regenerate.
+func (mr *MockStoreMockRecorder) Regenerate(ctx, sid, newSID, grace, modify interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Regenerate", reflect.TypeOf((*MockStore)(nil).Regenerate), ctx, sid, newSID, grace, modify)
+}
+
 // RemoveAttributes mocks base method.
 func (m *MockStore) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*session.Session, error) {
 	m.ctrl.T.Helper()
@@ -113,3 +238,18 @@ func (mr *MockStoreMockRecorder) Save(ctx, s interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockStore)(nil).Save), ctx, s)
 }
+
+// Update mocks base method.
+func (m *MockStore) Update(ctx context.Context, s *session.Session) (*session.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, s)
+	ret0, _ := ret[0].(*session.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockStoreMockRecorder) Update(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStore)(nil).Update), ctx, s)
+}
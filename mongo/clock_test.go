@@ -0,0 +1,20 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session/storetest"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNowExpression(t *testing.T) {
+	ms := NewMongoStore(nil, logr.Discard(), "key").(*mongoStore)
+	assert.Equal(t, "$$NOW", ms.now())
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms = NewMongoStore(nil, logr.Discard(), "key", WithClock(storetest.NewFakeClock(now))).(*mongoStore)
+	assert.Equal(t, literal(now), ms.now())
+	assert.Equal(t, now, ms.appNow())
+}
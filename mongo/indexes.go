@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sessionIndexes return indexes required by the store queries
+func sessionIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"sid", 1}},
+			Options: options.Index().SetName("sid").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{"uid", 1}, {"active", 1}},
+			Options: options.Index().SetName("uid_active"),
+		},
+		{
+			// expires_at is maintained on every write, see expiresAtStage
+			Keys:    bson.D{{"expires_at", 1}},
+			Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+		},
+	}
+}
+
+/*
+EnsureIndexes create indexes used by the store in the session collection.
+
+Indexes:
+  - unique index on sid
+  - index on uid and active used to list and invalidate user sessions
+  - TTL index on expires_at, documents are removed by MongoDB once
+    the session is expired by idle or absolute timeout
+
+expires_at is computed on every write of the store,
+documents written before it was introduced are backfilled by EnsureIndexes.
+
+It's safe to call it on every application start,
+existing indexes with the same definition are left as is.
+Creating the unique index fails if the collection already contains duplicated sid.
+*/
+func EnsureIndexes(ctx context.Context, c *mongo.Collection) error {
+	_, err := c.UpdateMany(ctx, bson.D{{"expires_at", bson.D{{"$exists", false}}}}, bson.A{expiresAtStage()})
+	if err != nil {
+		return fmt.Errorf("session.mongo.EnsureIndexes() UpdateMany() error: %w", err)
+	}
+
+	_, err = c.Indexes().CreateMany(ctx, sessionIndexes())
+	if err != nil {
+		return fmt.Errorf("session.mongo.EnsureIndexes() CreateMany() error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/asstart/go-session"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Attribute paths are applied with expressions building documents field by field
+// instead of dotted field paths, because field paths are evaluated through arrays
+// and would change every element of an array on the path.
+// Segments of paths are validated by session.ValidateAttributeKeys,
+// so they are safe to be used as field names of expression objects.
+
+// pathTree is tree of attribute path segments,
+// inner nodes are pathTree and leaves are expressions of values
+type pathTree map[string]interface{}
+
+// newPathTree build tree from attribute paths and expressions of their values,
+// paths mustn't overlap
+func newPathTree(leaves map[string]interface{}) pathTree {
+	t := pathTree{}
+	for k, leaf := range leaves {
+		segs := session.SplitAttributePath(k)
+		node := t
+		for _, seg := range segs[:len(segs)-1] {
+			next, ok := node[seg].(pathTree)
+			if !ok {
+				next = pathTree{}
+				node[seg] = next
+			}
+			node = next
+		}
+		node[segs[len(segs)-1]] = leaf
+	}
+	return t
+}
+
+func (t pathTree) segments() []string {
+	segs := make([]string, 0, len(t))
+	for seg := range t {
+		segs = append(segs, seg)
+	}
+	sort.Strings(segs)
+	return segs
+}
+
+// setExpr is expression of document at field path with leaves of t set,
+// missing documents are created and values which aren't documents are replaced
+func setExpr(path string, t pathTree) bson.D {
+	fields := bson.D{}
+	for _, seg := range t.segments() {
+		v := t[seg]
+		if sub, ok := v.(pathTree); ok {
+			v = setExpr(path+"."+seg, sub)
+		}
+		fields = append(fields, bson.E{seg, v})
+	}
+	return bson.D{{"$mergeObjects", bson.A{objectOrEmpty(path), fields}}}
+}
+
+// unsetExpr is expression of document at field path with leaves of t removed,
+// paths which don't lead to documents are left as is
+func unsetExpr(path string, t pathTree) bson.D {
+	removed := bson.A{}
+	fields := bson.D{}
+	for _, seg := range t.segments() {
+		sub, ok := t[seg].(pathTree)
+		if !ok {
+			removed = append(removed, seg)
+			continue
+		}
+		child := path + "." + seg
+		fields = append(fields, bson.E{seg, bson.D{{"$cond", bson.A{
+			isObject(child),
+			unsetExpr(child, sub),
+			"$$REMOVE",
+		}}}})
+	}
+
+	var obj interface{} = objectOrEmpty(path)
+	if len(removed) > 0 {
+		obj = bson.D{{"$arrayToObject", bson.D{{"$filter", bson.D{
+			{"input", bson.D{{"$objectToArray", obj}}},
+			{"as", "attr"},
+			{"cond", bson.D{{"$not", bson.A{
+				bson.D{{"$in", bson.A{"$$attr.k", literal(removed)}}},
+			}}}},
+		}}}}}
+	}
+	return bson.D{{"$mergeObjects", bson.A{obj, fields}}}
+}
+
+// valueExpr is expression of attribute value by path,
+// it's null if some of parents on the path isn't a document
+func valueExpr(key string) interface{} {
+	segs := session.SplitAttributePath(key)
+	path := dataPath(segs)
+	if len(segs) == 1 {
+		return path
+	}
+
+	parents := bson.A{}
+	for i := 1; i < len(segs); i++ {
+		parents = append(parents, isObject(dataPath(segs[:i])))
+	}
+	return bson.D{{"$cond", bson.A{bson.D{{"$and", parents}}, path, nil}}}
+}
+
+// typesStage is pipeline update stage replacing type names of attributes changed by paths with types,
+// type names of nested attributes and of parents of paths are removed as well
+func typesStage(paths []string, types map[string]string) bson.D {
+	touched := bson.A{}
+	for _, p := range paths {
+		touched = append(touched,
+			bson.D{{"$eq", bson.A{"$$t.path", literal(p)}}},
+			bson.D{{"$eq", bson.A{bson.D{{"$indexOfBytes", bson.A{"$$t.path", literal(p + session.AttributePathSeparator)}}}, 0}}},
+			bson.D{{"$eq", bson.A{bson.D{{"$indexOfBytes", bson.A{literal(p), bson.D{{"$concat", bson.A{"$$t.path", session.AttributePathSeparator}}}}}}, 0}}},
+		)
+	}
+
+	return bson.D{{"$set", bson.D{
+		{"types", bson.D{{"$concatArrays", bson.A{
+			bson.D{{"$filter", bson.D{
+				{"input", bson.D{{"$ifNull", bson.A{"$types", bson.A{}}}}},
+				{"as", "t"},
+				{"cond", bson.D{{"$not", bson.A{bson.D{{"$or", touched}}}}}},
+			}}},
+			literal(toMngAttrTypes(types)),
+		}}}},
+	}}}
+}
+
+// toMngAttrTypes return type names of attributes sorted by paths
+func toMngAttrTypes(types map[string]string) []mngAttrType {
+	r := make([]mngAttrType, 0, len(types))
+	for p, t := range types {
+		r = append(r, mngAttrType{Path: p, Type: t})
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Path < r[j].Path
+	})
+	return r
+}
+
+func dataPath(segs []string) string {
+	return "$data." + strings.Join(segs, ".")
+}
+
+func isObject(path string) bson.D {
+	return bson.D{{"$eq", bson.A{bson.D{{"$type", path}}, "object"}}}
+}
+
+func objectOrEmpty(path string) bson.D {
+	return bson.D{{"$cond", bson.A{isObject(path), path, bson.D{}}}}
+}
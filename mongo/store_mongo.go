@@ -22,15 +22,53 @@ type mongoStore struct {
 	Logger         logr.Logger
 	CustomRegistry *bsoncodec.Registry
 	CtxReqIDKey    interface{}
+	Clock          session.Clock
+	TouchThreshold float64
+	Coalescer      *TouchCoalescer
 }
 
-func NewMongoStore(c *mongo.Collection, l logr.Logger, reqIDKey interface{}) session.Store {
-	return &mongoStore{
+// Option configures store created by NewMongoStore
+type Option func(*mongoStore)
+
+// WithClock make the store write and compare timestamps with application time read from c
+// instead of database server time, so expiry doesn't depend on clock skew between them
+// and can be tested with a fake clock
+func WithClock(c session.Clock) Option {
+	return func(ms *mongoStore) {
+		ms.Clock = c
+	}
+}
+
+// WithTouchThreshold make Load bump last_accessed_at only if at least ratio of idle timeout
+// is passed since the previous bump, e.g. with 0.1 and 30 minutes idle timeout
+// a session is written by Load at most every 3 minutes, other Loads are served by a read.
+// Sessions may expire up to ratio of idle timeout earlier than when every Load touches them
+func WithTouchThreshold(ratio float64) Option {
+	return func(ms *mongoStore) {
+		ms.TouchThreshold = ratio
+	}
+}
+
+// WithTouchCoalescer make Load serve sessions by a read and hand touches over to tc,
+// which write them in batches (see TouchCoalescer). It's combined with WithTouchThreshold
+// to skip touches before they're scheduled
+func WithTouchCoalescer(tc *TouchCoalescer) Option {
+	return func(ms *mongoStore) {
+		ms.Coalescer = tc
+	}
+}
+
+func NewMongoStore(c *mongo.Collection, l logr.Logger, reqIDKey interface{}, opts ...Option) session.Store {
+	ms := &mongoStore{
 		Collecction:    c,
 		Logger:         l,
 		CustomRegistry: getCustomRegisry(),
 		CtxReqIDKey:    reqIDKey,
 	}
+	for _, o := range opts {
+		o(ms)
+	}
+	return ms
 }
 
 type mngSession struct {
@@ -45,6 +83,15 @@ type mngSession struct {
 	AbsTimeout     time.Duration          `bson:"abs_timeout"`
 	LastAccessedAt time.Time              `bson:"last_accessed_at"`
 	CreatedAt      time.Time              `bson:"created_at"`
+	Version        int64                  `bson:"version"`
+	Types          []mngAttrType          `bson:"types,omitempty"`
+}
+
+// mngAttrType is registered type name of attribute by its path (see session.RegisterType),
+// it's kept in array because paths can't be used as field names
+type mngAttrType struct {
+	Path string `bson:"path"`
+	Type string `bson:"type"`
 }
 
 type mngCookieConf struct {
@@ -68,6 +115,11 @@ func fromMngCookieConf(c mngCookieConf) session.CookieConf {
 }
 
 func fromMngSession(s *mngSession) session.Session {
+	types := make(map[string]string, len(s.Types))
+	for _, t := range s.Types {
+		types[t.Path] = t.Type
+	}
+	session.RestoreAttributeTypes(s.Data, types)
 
 	return session.Session{
 		ID:             s.SID,
@@ -80,6 +132,7 @@ func fromMngSession(s *mngSession) session.Session {
 		AbsTimeout:     s.AbsTimeout,
 		LastAccessedAt: s.LastAccessedAt,
 		CreatedAt:      s.CreatedAt,
+		Version:        s.Version,
 	}
 }
 
@@ -88,31 +141,62 @@ func (ms *mongoStore) Save(ctx context.Context, s *session.Session) (*session.Se
 	ms.Logger.V(0).Info("session.mongo.Save() started", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 	defer ms.Logger.V(0).Info("session.mongo.Save() finished", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 
+	f := bson.D{
+		{"sid", s.ID},
+	}
+	o := bson.A{
+		bson.D{{"$set", append(bson.D{{"sid", literal(s.ID)}}, sessionFields(s)...)}},
+		bson.D{{"$set", bson.D{
+			{"last_accessed_at", ms.now()},
+			{"created_at", bson.D{{"$ifNull", bson.A{"$created_at", ms.now()}}}},
+		}}},
+		// replaced session can be regenerated again
+		bson.D{{"$unset", "regenerated_to"}},
+		versionStage(),
+		expiresAtStage(),
+	}
+
+	opts := options.FindOneAndUpdate()
+	opts = opts.SetUpsert(true)
+	opts = opts.SetReturnDocument(options.After)
+
+	var updS mngSession
+	sr := ms.Collecction.FindOneAndUpdate(ctx, f, o, opts)
+	err := decodeWithRegistry(ms.CustomRegistry, sr, &updS)
+
+	if err != nil {
+		err = fmt.Errorf("session.mongo.Save() FindOneAndUpdate() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.Save() FindOneAndUpdate() unexpected error",
+			session.LogKeySID, s.ID,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+
+		return nil, err
+	}
+
+	r := fromMngSession(&updS)
+
+	return &r, nil
+}
+
+func (ms *mongoStore) Create(ctx context.Context, s *session.Session) (*session.Session, error) {
+
+	ms.Logger.V(0).Info("session.mongo.Create() started", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.Create() finished", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	// upsert of a new document always inserts,
+	// collision with existing sid is detected by the unique index
 	f := bson.D{
 		{"_id", primitive.NewObjectID()},
 	}
-	o := bson.D{
-		{"$set", bson.D{
-			{"sid", s.ID},
-			{"data", s.Data},
-			{"opts", bson.D{
-				{"path", s.Opts.Path},
-				{"domain", s.Opts.Domain},
-				{"secure", s.Opts.Secure},
-				{"http_only", s.Opts.HTTPOnly},
-				{"max_age", s.Opts.MaxAge},
-				{"same_site", s.Opts.SameSite},
-			}},
-			{"anonym", s.Anonym},
-			{"active", s.Active},
-			{"uid", s.UID},
-			{"idle_timeout", s.IdleTimeout},
-			{"abs_timeout", s.AbsTimeout},
-		}},
-		{"$currentDate", bson.D{
-			{"last_accessed_at", true},
-			{"created_at", true},
-		}},
+	o := bson.A{
+		bson.D{{"$set", append(bson.D{{"sid", literal(s.ID)}}, sessionFields(s)...)}},
+		bson.D{{"$set", bson.D{
+			{"last_accessed_at", ms.now()},
+			{"created_at", ms.now()},
+		}}},
+		versionStage(),
+		expiresAtStage(),
 	}
 
 	opts := options.FindOneAndUpdate()
@@ -123,14 +207,14 @@ func (ms *mongoStore) Save(ctx context.Context, s *session.Session) (*session.Se
 	sr := ms.Collecction.FindOneAndUpdate(ctx, f, o, opts)
 	err := decodeWithRegistry(ms.CustomRegistry, sr, &updS)
 
-	if err == mongo.ErrNoDocuments {
-		ms.Logger.V(0).Info("session.mong.Save() session not found", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
-		return nil, session.ErrSessionNotFound
+	if mongo.IsDuplicateKeyError(err) {
+		ms.Logger.V(0).Info("session.mongo.Create() session already exists", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, session.ErrSessionExists
 	}
 
 	if err != nil {
-		err = fmt.Errorf("session.mong.Save() FindOneAndUpdate() unexpected error: %w", err)
-		ms.Logger.V(0).Info("session.mong.Save() session not found",
+		err = fmt.Errorf("session.mongo.Create() FindOneAndUpdate() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.Create() FindOneAndUpdate() unexpected error",
 			session.LogKeySID, s.ID,
 			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
 			session.LogKeyDebugError, err)
@@ -151,16 +235,16 @@ func (ms *mongoStore) Invalidate(ctx context.Context, sid string) error {
 		{"sid", sid},
 	}
 
-	op := bson.D{
-		{"$set", bson.D{
+	op := bson.A{
+		bson.D{{"$set", bson.D{
 			{"active", false},
-		}},
-		{"$currentDate", bson.D{
-			{"last_accessed_at", true},
-		}},
+			{"last_accessed_at", ms.now()},
+		}}},
+		versionStage(),
+		expiresAtStage(),
 	}
 
-	_, err := ms.Collecction.UpdateOne(ctx, f, op)
+	res, err := ms.Collecction.UpdateOne(ctx, f, op)
 	if err != nil {
 		err = fmt.Errorf("session.mongo.Invalidate error: %w", err)
 		ms.Logger.V(0).Info(
@@ -170,35 +254,24 @@ func (ms *mongoStore) Invalidate(ctx context.Context, sid string) error {
 			session.LogKeyDebugError, err)
 		return err
 	}
+
+	if res.MatchedCount == 0 {
+		ms.Logger.V(0).Info("session.mongo.Invalidate() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return session.ErrSessionNotFound
+	}
+
 	return nil
 }
 
 func (ms *mongoStore) Update(ctx context.Context, s *session.Session) (*session.Session, error) {
 	ms.Logger.V(0).Info("session.mongo.Update() started", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 	defer ms.Logger.V(0).Info("session.mongo.Update() finished", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
-	f := bson.D{
-		{"sid", s.ID},
-	}
-	obj := bson.D{
-		{"$set", bson.D{
-			{"data", s.Data},
-			{"opts", bson.D{
-				{"path", s.Opts.Path},
-				{"domain", s.Opts.Domain},
-				{"secure", s.Opts.Secure},
-				{"http_only", s.Opts.HTTPOnly},
-				{"max_age", s.Opts.MaxAge},
-				{"same_site", s.Opts.SameSite},
-			}},
-			{"anonym", s.Anonym},
-			{"active", s.Active},
-			{"uid", s.UID},
-			{"idle_timeout", s.IdleTimeout},
-			{"abs_timeout", s.AbsTimeout},
-		}},
-		{"$currentDate", bson.D{
-			{"last_accessed_at", true},
-		}},
+
+	f := append(ms.liveFilter(s.ID), versionFilter(s.Version))
+	obj := bson.A{
+		bson.D{{"$set", append(sessionFields(s), bson.E{"last_accessed_at", ms.now()})}},
+		versionStage(),
+		expiresAtStage(),
 	}
 
 	opts := options.FindOneAndUpdate()
@@ -209,13 +282,13 @@ func (ms *mongoStore) Update(ctx context.Context, s *session.Session) (*session.
 	err := decodeWithRegistry(ms.CustomRegistry, sr, &updS)
 
 	if err == mongo.ErrNoDocuments {
-		ms.Logger.V(0).Info("session.mong.Update() session not found", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
-		return nil, session.ErrSessionNotFound
+		ms.Logger.V(0).Info("session.mongo.Update() session not matched", session.LogKeySID, s.ID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, ms.liveMissErr(ctx, s.ID, session.ErrVersionConflict)
 	}
 
 	if err != nil {
-		err = fmt.Errorf("session.mong.Update() FindOneAndUpdate() unexpected error: %w", err)
-		ms.Logger.V(0).Info("session.mong.Update() session not found",
+		err = fmt.Errorf("session.mongo.Update() FindOneAndUpdate() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.Update() FindOneAndUpdate() unexpected error",
 			session.LogKeySID, s.ID,
 			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
 			session.LogKeyDebugError, err)
@@ -232,12 +305,64 @@ func (ms *mongoStore) Load(ctx context.Context, sid string) (*session.Session, e
 	ms.Logger.V(0).Info("session.mongo.Load() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 	defer ms.Logger.V(0).Info("session.mongo.Load() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 
-	f := bson.D{
-		{"sid", sid},
+	if ms.TouchThreshold <= 0 && ms.Coalescer == nil {
+		return ms.touch(ctx, sid)
+	}
+
+	s, err := ms.find(ctx, "Load", sid)
+	if err != nil {
+		return nil, err
 	}
 
-	upd := bson.D{
-		{"$currentDate", bson.D{{"last_accessed_at", true}}},
+	// BSON datetime has millisecond precision
+	now := ms.appNow().Truncate(time.Millisecond)
+	if now.Sub(s.LastAccessedAt) < time.Duration(ms.TouchThreshold*float64(s.IdleTimeout)) {
+		return s, nil
+	}
+
+	if ms.Coalescer != nil {
+		ms.Coalescer.Touch(sid, now)
+		s.LastAccessedAt = now
+		return s, nil
+	}
+
+	return ms.touch(ctx, sid)
+}
+
+func (ms *mongoStore) Peek(ctx context.Context, sid string) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.mongo.Peek() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.Peek() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	return ms.find(ctx, "Peek", sid)
+}
+
+func (ms *mongoStore) Exists(ctx context.Context, sid string) (bool, error) {
+	ms.Logger.V(0).Info("session.mongo.Exists() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.Exists() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	opts := options.Count()
+	opts = opts.SetLimit(1)
+
+	n, err := ms.Collecction.CountDocuments(ctx, ms.liveFilter(sid), opts)
+	if err != nil {
+		err = fmt.Errorf("session.mongo.Exists() CountDocuments() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.Exists() CountDocuments() unexpected error",
+			session.LogKeySID, sid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// touch bump last_accessed_at of live session and return it
+func (ms *mongoStore) touch(ctx context.Context, sid string) (*session.Session, error) {
+	f := ms.liveFilter(sid)
+
+	upd := bson.A{
+		bson.D{{"$set", bson.D{{"last_accessed_at", ms.now()}}}},
+		expiresAtStage(),
 	}
 
 	opts := options.FindOneAndUpdate()
@@ -248,13 +373,39 @@ func (ms *mongoStore) Load(ctx context.Context, sid string) (*session.Session, e
 	err := decodeWithRegistry(ms.CustomRegistry, sr, &s)
 
 	if err == mongo.ErrNoDocuments {
-		ms.Logger.V(0).Info("session.mong.Load() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
-		return nil, session.ErrSessionNotFound
+		ms.Logger.V(0).Info("session.mongo.Load() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, ms.notLiveErr(ctx, sid)
 	}
 
 	if err != nil {
-		err = fmt.Errorf("session.mong.Load() FindOneAndUpdate() unexpected error: %w", err)
-		ms.Logger.V(0).Info("session.mong.Load() session not found",
+		err = fmt.Errorf("session.mongo.Load() FindOneAndUpdate() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.Load() FindOneAndUpdate() unexpected error",
+			session.LogKeySID, sid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+
+		return nil, err
+	}
+
+	r := fromMngSession(&s)
+
+	return &r, nil
+}
+
+// find return live session without touching it
+func (ms *mongoStore) find(ctx context.Context, op, sid string) (*session.Session, error) {
+	s := mngSession{}
+	sr := ms.Collecction.FindOne(ctx, ms.liveFilter(sid))
+	err := decodeWithRegistry(ms.CustomRegistry, sr, &s)
+
+	if err == mongo.ErrNoDocuments {
+		ms.Logger.V(0).Info("session.mongo."+op+"() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, ms.notLiveErr(ctx, sid)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.mongo.%s() FindOne() unexpected error: %w", op, err)
+		ms.Logger.V(0).Info("session.mongo."+op+"() FindOne() unexpected error",
 			session.LogKeySID, sid,
 			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
 			session.LogKeyDebugError, err)
@@ -271,31 +422,46 @@ func (ms *mongoStore) AddAttributes(ctx context.Context, sid string, data map[st
 	ms.Logger.V(0).Info("session.mongo.AddAttributes() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 	defer ms.Logger.V(0).Info("session.mongo.AddAttributes() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 
-	f := bson.M{"sid": sid}
+	keys := make([]string, 0, len(data))
+	leaves := make(map[string]interface{}, len(data))
+	types := map[string]string{}
+	for k, v := range data {
+		keys = append(keys, k)
+		leaves[k] = literal(v)
+		for p, t := range session.AttributeTypes(k, v) {
+			types[p] = t
+		}
+	}
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		ms.Logger.V(0).Info("session.mongo.AddAttributes() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	f := ms.liveFilter(sid)
 	up := bson.A{
 		bson.D{{"$set", bson.D{
-			{"data", bson.D{
-				{"$mergeObjects", bson.A{
-					"$data", data,
-				}},
-			}},
+			{"data", setExpr("$data", newPathTree(leaves))},
 		}}},
+		typesStage(keys, types),
 		bson.D{{"$addFields",
 			bson.D{
-				{"last_accessed_at", "$$NOW"},
+				{"last_accessed_at", ms.now()},
 			},
 		}},
+		versionStage(),
+		expiresAtStage(),
 	}
 	opt := options.FindOneAndUpdate()
 	opt.SetReturnDocument(options.After)
 
 	var s mngSession
 	sr := ms.Collecction.FindOneAndUpdate(ctx, f, up, opt)
-	err := decodeWithRegistry(ms.CustomRegistry, sr, &s)
+	err = decodeWithRegistry(ms.CustomRegistry, sr, &s)
 
 	if err == mongo.ErrNoDocuments {
 		ms.Logger.V(0).Info("session.mongo.AddAttributes() FindOneAndUpdate() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
-		return nil, session.ErrSessionNotFound
+		return nil, ms.notLiveErr(ctx, sid)
 	}
 
 	if err != nil {
@@ -312,39 +478,159 @@ func (ms *mongoStore) AddAttributes(ctx context.Context, sid string, data map[st
 	return &r, nil
 }
 
+func (ms *mongoStore) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.mongo.IncrementAttribute() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.IncrementAttribute() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		ms.Logger.V(0).Info("session.mongo.IncrementAttribute() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	// pipeline equivalent of $inc, so expires_at and version are maintained by the same update
+	value := valueExpr(key)
+	f := bson.D{{"$and", bson.A{
+		ms.liveFilter(sid),
+		attrTypeFilter(value, "int", "long", "double", "decimal"),
+	}}}
+	inc := bson.D{{"$add", bson.A{
+		bson.D{{"$ifNull", bson.A{value, 0}}},
+		delta,
+	}}}
+	up := bson.A{
+		bson.D{{"$set", bson.D{
+			{"data", setExpr("$data", newPathTree(map[string]interface{}{key: inc}))},
+			{"last_accessed_at", ms.now()},
+		}}},
+		// result has BSON numeric type the same way as in other stores
+		typesStage([]string{key}, nil),
+		versionStage(),
+		expiresAtStage(),
+	}
+
+	return ms.updateAttribute(ctx, "IncrementAttribute", sid, f, up)
+}
+
+func (ms *mongoStore) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.mongo.AppendToAttribute() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.AppendToAttribute() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	err := session.ValidateAttributeKey(key)
+	if err != nil {
+		ms.Logger.V(0).Info("session.mongo.AppendToAttribute() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	// pipeline equivalent of $push with $slice, so expires_at and version are maintained by the same update
+	value := valueExpr(key)
+	f := bson.D{{"$and", bson.A{
+		ms.liveFilter(sid),
+		attrTypeFilter(value, "array"),
+	}}}
+	var list interface{} = bson.D{{"$concatArrays", bson.A{
+		bson.D{{"$ifNull", bson.A{value, bson.A{}}}},
+		literal(values),
+	}}}
+	if maxLen > 0 {
+		list = bson.D{{"$slice", bson.A{list, -maxLen}}}
+	}
+	up := bson.A{
+		bson.D{{"$set", bson.D{
+			{"data", setExpr("$data", newPathTree(map[string]interface{}{key: list}))},
+			{"last_accessed_at", ms.now()},
+		}}},
+		// result is list of interface{} the same way as in other stores
+		typesStage([]string{key}, nil),
+		versionStage(),
+		expiresAtStage(),
+	}
+
+	return ms.updateAttribute(ctx, "AppendToAttribute", sid, f, up)
+}
+
+// updateAttribute run update of a single attribute guarded by attrTypeFilter,
+// op is name of the store method used in logs and errors
+func (ms *mongoStore) updateAttribute(ctx context.Context, op, sid string, f bson.D, up bson.A) (*session.Session, error) {
+	opt := options.FindOneAndUpdate()
+	opt.SetReturnDocument(options.After)
+
+	var s mngSession
+	sr := ms.Collecction.FindOneAndUpdate(ctx, f, up, opt)
+	err := decodeWithRegistry(ms.CustomRegistry, sr, &s)
+
+	if err == mongo.ErrNoDocuments {
+		ms.Logger.V(0).Info("session.mongo."+op+"() FindOneAndUpdate() session not matched", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, ms.liveMissErr(ctx, sid, session.ErrAttributeType)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.mongo.%v() FindOneAndUpdate() unexpected error: %w", op, err)
+		ms.Logger.V(0).Info("session.mongo."+op+"() FindOneAndUpdate() unexpected error",
+			session.LogKeySID, sid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err,
+		)
+		return nil, err
+	}
+
+	r := fromMngSession(&s)
+	return &r, nil
+}
+
+// attrTypeFilter match document if value expression is missing, null or has one of BSON types
+func attrTypeFilter(value interface{}, types ...string) bson.D {
+	allowed := bson.A{"missing", "null"}
+	for _, t := range types {
+		allowed = append(allowed, t)
+	}
+	return bson.D{{"$expr", bson.D{{"$in", bson.A{bson.D{{"$type", value}}, allowed}}}}}
+}
+
 func (ms *mongoStore) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*session.Session, error) {
 	ms.Logger.V(0).Info("session.mongo.RemoveAttributes() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 	defer ms.Logger.V(0).Info("session.mongo.RemoveAttributes() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
 
-	fullkeys := []string{}
+	err := session.ValidateAttributeKeys(keys...)
+	if err != nil {
+		ms.Logger.V(0).Info("session.mongo.RemoveAttributes() invalid key", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey), session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	leaves := make(map[string]interface{}, len(keys))
 	for _, k := range keys {
-		fullkeys = append(fullkeys, fmt.Sprintf("data.%v", k))
+		leaves[k] = true
 	}
 
-	f := bson.M{"sid": sid}
+	f := ms.liveFilter(sid)
 	up := bson.A{
-		bson.D{{"$unset", fullkeys}},
+		bson.D{{"$set", bson.D{
+			{"data", unsetExpr("$data", newPathTree(leaves))},
+		}}},
+		typesStage(keys, nil),
 		bson.D{{"$addFields",
 			bson.D{
-				{"last_accessed_at", "$$NOW"},
+				{"last_accessed_at", ms.now()},
 			},
 		}},
+		versionStage(),
+		expiresAtStage(),
 	}
 	opt := options.FindOneAndUpdate()
 	opt.SetReturnDocument(options.After)
 
 	var s mngSession
 	sr := ms.Collecction.FindOneAndUpdate(ctx, f, up, opt)
-	err := decodeWithRegistry(ms.CustomRegistry, sr, &s)
+	err = decodeWithRegistry(ms.CustomRegistry, sr, &s)
 
 	if err == mongo.ErrNoDocuments {
-		ms.Logger.V(0).Info("session.mongo.AddAttributes() FindOneAndUpdate() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
-		return nil, session.ErrSessionNotFound
+		ms.Logger.V(0).Info("session.mongo.RemoveAttributes() FindOneAndUpdate() session not found", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		return nil, ms.notLiveErr(ctx, sid)
 	}
 
 	if err != nil {
-		err = fmt.Errorf("session.mongo.AddAttributes() FindOneAndUpdate() unexpected error: %w", err)
-		ms.Logger.V(0).Info("session.mongo.AddAttributes() FindOneAndUpdate() unexpected error",
+		err = fmt.Errorf("session.mongo.RemoveAttributes() FindOneAndUpdate() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.RemoveAttributes() FindOneAndUpdate() unexpected error",
 			session.LogKeySID, sid,
 			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
 			session.LogKeyDebugError, err,
@@ -356,6 +642,384 @@ func (ms *mongoStore) RemoveAttributes(ctx context.Context, sid string, keys ...
 	return &r, nil
 }
 
+/*
+Regenerate retire active session and copy it to newSID.
+
+It isn't a transaction: the old session is retired first and marked with regenerated_to,
+so only one of concurrent Regenerate calls succeeds even if the old session stays active for grace,
+the others get ErrVersionConflict. Then its copy is stored with the new id,
+if it fails the old session is restored.
+*/
+func (ms *mongoStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*session.Session)) (*session.Session, error) {
+	ms.Logger.V(0).Info("session.mongo.Regenerate() started", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.Regenerate() finished", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	f := append(ms.liveFilter(sid), bson.E{"regenerated_to", bson.D{{"$exists", false}}})
+
+	retire := bson.A{
+		bson.D{{"$set", bson.D{
+			{"active", false},
+			{"regenerated_to", literal(newSID)},
+		}}},
+		versionStage(),
+		expiresAtStage(),
+	}
+	if grace > 0 {
+		// shorten abs_timeout (stored in nanoseconds) so session expires in grace from now,
+		// subtraction of dates returns milliseconds
+		retire = bson.A{
+			bson.D{{"$set", bson.D{
+				{"abs_timeout", bson.D{{"$min", bson.A{
+					"$abs_timeout",
+					bson.D{{"$multiply", bson.A{
+						bson.D{{"$subtract", bson.A{
+							bson.D{{"$add", bson.A{ms.now(), grace.Milliseconds()}}},
+							"$created_at",
+						}}},
+						int64(time.Millisecond),
+					}}},
+				}}}},
+				{"regenerated_to", literal(newSID)},
+			}}},
+			versionStage(),
+			expiresAtStage(),
+		}
+	}
+
+	opts := options.FindOneAndUpdate()
+	opts = opts.SetReturnDocument(options.Before)
+
+	var old mngSession
+	sr := ms.Collecction.FindOneAndUpdate(ctx, f, retire, opts)
+	err := decodeWithRegistry(ms.CustomRegistry, sr, &old)
+
+	if err == mongo.ErrNoDocuments {
+		ms.Logger.V(0).Info("session.mongo.Regenerate() session not available", session.LogKeySID, sid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		// live session is already regenerated and kept for grace
+		return nil, ms.liveMissErr(ctx, sid, session.ErrVersionConflict)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.mongo.Regenerate() FindOneAndUpdate() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.Regenerate() FindOneAndUpdate() unexpected error",
+			session.LogKeySID, sid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	ns := fromMngSession(&old)
+	if modify != nil {
+		modify(&ns)
+	}
+	ns.ID = newSID
+	ns.Active = true
+
+	f = bson.D{
+		{"_id", primitive.NewObjectID()},
+	}
+	o := bson.A{
+		bson.D{{"$set", append(bson.D{{"sid", literal(ns.ID)}, {"created_at", literal(old.CreatedAt)}}, sessionFields(&ns)...)}},
+		bson.D{{"$set", bson.D{
+			{"last_accessed_at", ms.now()},
+			{"version", old.Version + 1},
+		}}},
+		expiresAtStage(),
+	}
+
+	opts = options.FindOneAndUpdate()
+	opts = opts.SetUpsert(true)
+	opts = opts.SetReturnDocument(options.After)
+
+	var updS mngSession
+	sr = ms.Collecction.FindOneAndUpdate(ctx, f, o, opts)
+	err = decodeWithRegistry(ms.CustomRegistry, sr, &updS)
+
+	if mongo.IsDuplicateKeyError(err) {
+		ms.Logger.V(0).Info("session.mongo.Regenerate() session already exists", session.LogKeySID, newSID, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+		ms.restore(ctx, &old)
+		return nil, session.ErrSessionExists
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.mongo.Regenerate() FindOneAndUpdate() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.Regenerate() FindOneAndUpdate() unexpected error",
+			session.LogKeySID, newSID,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		ms.restore(ctx, &old)
+		return nil, err
+	}
+
+	r := fromMngSession(&updS)
+
+	return &r, nil
+}
+
+// restore make retired by Regenerate session live again,
+// it's best effort, error is only logged
+func (ms *mongoStore) restore(ctx context.Context, old *mngSession) {
+	f := bson.D{
+		{"_id", old.ID},
+	}
+	o := bson.A{
+		bson.D{{"$set", bson.D{
+			{"active", old.Active},
+			{"abs_timeout", old.AbsTimeout},
+		}}},
+		bson.D{{"$unset", "regenerated_to"}},
+		versionStage(),
+		expiresAtStage(),
+	}
+
+	_, err := ms.Collecction.UpdateOne(ctx, f, o)
+	if err != nil {
+		ms.Logger.V(0).Info("session.mongo.restore() UpdateOne() unexpected error",
+			session.LogKeySID, old.SID,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+	}
+}
+
+func (ms *mongoStore) ListUserSessions(ctx context.Context, uid string) ([]*session.Session, error) {
+	ms.Logger.V(0).Info("session.mongo.ListUserSessions() started", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.ListUserSessions() finished", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	f := bson.D{
+		{"uid", uid},
+		{"active", true},
+	}
+
+	opts := options.Find()
+	opts = opts.SetSort(bson.D{{"last_accessed_at", -1}})
+
+	cur, err := ms.Collecction.Find(ctx, f, opts)
+	if err != nil {
+		err = fmt.Errorf("session.mongo.ListUserSessions() Find() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.ListUserSessions() Find() unexpected error",
+			session.LogKeyUID, uid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	sessions := []*session.Session{}
+	for cur.Next(ctx) {
+		var s mngSession
+		err = bson.UnmarshalWithRegistry(ms.CustomRegistry, cur.Current, &s)
+		if err != nil {
+			err = fmt.Errorf("session.mongo.ListUserSessions() decode unexpected error: %w", err)
+			ms.Logger.V(0).Info("session.mongo.ListUserSessions() decode unexpected error",
+				session.LogKeyUID, uid,
+				session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+				session.LogKeyDebugError, err)
+			return nil, err
+		}
+
+		r := fromMngSession(&s)
+		if !r.IsExpiredAt(ms.appNow()) {
+			sessions = append(sessions, &r)
+		}
+	}
+
+	if err = cur.Err(); err != nil {
+		err = fmt.Errorf("session.mongo.ListUserSessions() cursor unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.ListUserSessions() cursor unexpected error",
+			session.LogKeyUID, uid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (ms *mongoStore) InvalidateUserSessions(ctx context.Context, uid string, exceptSIDs ...string) error {
+	ms.Logger.V(0).Info("session.mongo.InvalidateUserSessions() started", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+	defer ms.Logger.V(0).Info("session.mongo.InvalidateUserSessions() finished", session.LogKeyUID, uid, session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey))
+
+	f := bson.D{
+		{"uid", uid},
+		{"active", true},
+	}
+	if len(exceptSIDs) > 0 {
+		f = append(f, bson.E{"sid", bson.D{{"$nin", exceptSIDs}}})
+	}
+
+	op := bson.A{
+		bson.D{{"$set", bson.D{
+			{"active", false},
+			{"last_accessed_at", ms.now()},
+		}}},
+		versionStage(),
+		expiresAtStage(),
+	}
+
+	_, err := ms.Collecction.UpdateMany(ctx, f, op)
+	if err != nil {
+		err = fmt.Errorf("session.mongo.InvalidateUserSessions() UpdateMany() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.InvalidateUserSessions() UpdateMany() unexpected error",
+			session.LogKeyUID, uid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		return err
+	}
+
+	return nil
+}
+
+// liveFilter match session by sid only if it's active and not expired
+func (ms *mongoStore) liveFilter(sid string) bson.D {
+	return liveFilterAt(sid, ms.now())
+}
+
+// liveFilterAt match session by sid only if it's active and not expired at time expression now
+func liveFilterAt(sid string, now interface{}) bson.D {
+	return bson.D{
+		{"sid", sid},
+		{"active", true},
+		{"$expr", bson.D{{"$and", bson.A{
+			bson.D{{"$gte", bson.A{idleExpiry(), now}}},
+			bson.D{{"$gte", bson.A{absExpiry(), now}}},
+		}}}},
+	}
+}
+
+// now is expression of current time, database server time is used if clock isn't set
+func (ms *mongoStore) now() interface{} {
+	if ms.Clock == nil {
+		return "$$NOW"
+	}
+	return literal(ms.Clock.Now())
+}
+
+// appNow return current time for checks done by the application
+func (ms *mongoStore) appNow() time.Time {
+	if ms.Clock == nil {
+		return time.Now()
+	}
+	return ms.Clock.Now()
+}
+
+// idleExpiry is expression of time when session expires by idle timeout,
+// timeouts are stored in nanoseconds and adding a number to a date adds milliseconds
+func idleExpiry() bson.D {
+	return bson.D{{"$add", bson.A{"$last_accessed_at", bson.D{{"$divide", bson.A{"$idle_timeout", int64(time.Millisecond)}}}}}}
+}
+
+// absExpiry is expression of time when session expires by absolute timeout
+func absExpiry() bson.D {
+	return bson.D{{"$add", bson.A{"$created_at", bson.D{{"$divide", bson.A{"$abs_timeout", int64(time.Millisecond)}}}}}}
+}
+
+// expiresAtStage is pipeline update stage maintaining expires_at used by TTL index,
+// it should be the last stage of every update changing timestamps or timeouts
+func expiresAtStage() bson.D {
+	return bson.D{{"$set", bson.D{
+		{"expires_at", bson.D{{"$min", bson.A{idleExpiry(), absExpiry()}}}},
+	}}}
+}
+
+// versionStage is pipeline update stage incrementing version,
+// it should be added to every update changing the session except touching last_accessed_at
+func versionStage() bson.D {
+	return bson.D{{"$set", bson.D{
+		{"version", bson.D{{"$add", bson.A{bson.D{{"$ifNull", bson.A{"$version", 0}}}, 1}}}},
+	}}}
+}
+
+// versionFilter match document with version v,
+// documents written before version was introduced have no version and match 0
+func versionFilter(v int64) bson.E {
+	if v == 0 {
+		return bson.E{"version", bson.D{{"$in", bson.A{0, nil}}}}
+	}
+	return bson.E{"version", v}
+}
+
+// literal prevent interpretation of v as an expression in pipeline updates,
+// e.g. strings starting with $ as field paths
+func literal(v interface{}) bson.D {
+	return bson.D{{"$literal", v}}
+}
+
+// notLiveErr explain why session isn't matched by liveFilter,
+// it return ErrSessionInvalidated, ErrSessionExpired or ErrSessionNotFound
+func (ms *mongoStore) notLiveErr(ctx context.Context, sid string) error {
+	opts := options.FindOne()
+	opts = opts.SetProjection(bson.D{{"data", 0}})
+
+	var s mngSession
+	sr := ms.Collecction.FindOne(ctx, bson.D{{"sid", sid}}, opts)
+	err := decodeWithRegistry(ms.CustomRegistry, sr, &s)
+	if err == mongo.ErrNoDocuments {
+		return session.ErrSessionNotFound
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.mongo.notLiveErr() FindOne() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.notLiveErr() FindOne() unexpected error",
+			session.LogKeySID, sid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		return err
+	}
+
+	r := fromMngSession(&s)
+	err = r.CheckExpiredAt(ms.appNow())
+	if err == nil {
+		// session was matched by neither liveFilter nor expiry check,
+		// it's possible only with clock skew between application and database
+		return session.ErrSessionExpired
+	}
+	return err
+}
+
+// liveMissErr classify why an update with a condition in addition to liveFilter didn't match the session,
+// liveErr is returned if the session is still live, so the condition isn't met
+func (ms *mongoStore) liveMissErr(ctx context.Context, sid string, liveErr error) error {
+	opts := options.Count()
+	opts = opts.SetLimit(1)
+
+	n, err := ms.Collecction.CountDocuments(ctx, ms.liveFilter(sid), opts)
+	if err != nil {
+		err = fmt.Errorf("session.mongo.liveMissErr() CountDocuments() unexpected error: %w", err)
+		ms.Logger.V(0).Info("session.mongo.liveMissErr() CountDocuments() unexpected error",
+			session.LogKeySID, sid,
+			session.LogKeyRQID, ctx.Value(ms.CtxReqIDKey),
+			session.LogKeyDebugError, err)
+		return err
+	}
+
+	if n > 0 {
+		return liveErr
+	}
+	return ms.notLiveErr(ctx, sid)
+}
+
+// sessionFields return fields of the session document which can be set by the store user,
+// values are wrapped with $literal to be used in pipeline updates
+func sessionFields(s *session.Session) bson.D {
+	return bson.D{
+		{"data", literal(s.Data)},
+		{"types", literal(toMngAttrTypes(session.DataTypes(s.Data)))},
+		{"opts", literal(bson.D{
+			{"path", s.Opts.Path},
+			{"domain", s.Opts.Domain},
+			{"secure", s.Opts.Secure},
+			{"http_only", s.Opts.HTTPOnly},
+			{"max_age", s.Opts.MaxAge},
+			{"same_site", s.Opts.SameSite},
+		})},
+		{"anonym", s.Anonym},
+		{"active", s.Active},
+		{"uid", literal(s.UID)},
+		{"idle_timeout", s.IdleTimeout},
+		{"abs_timeout", s.AbsTimeout},
+	}
+}
+
 func decodeWithRegistry(r *bsoncodec.Registry, sr *mongo.SingleResult, v interface{}) error {
 	if sr.Err() != nil {
 		return sr.Err()
@@ -377,6 +1041,7 @@ func getCustomRegisry() *bsoncodec.Registry {
 
 	rb.RegisterTypeMapEntry(bsontype.DateTime, reflect.TypeOf(time.Time{}))
 	rb.RegisterTypeMapEntry(bson.TypeArray, reflect.TypeOf([]interface{}{}))
+	rb.RegisterTypeMapEntry(bson.TypeEmbeddedDocument, reflect.TypeOf(map[string]interface{}{}))
 
 	return rb.Build()
 }
@@ -0,0 +1,181 @@
+package mongo_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	smongo "github.com/asstart/go-session/mongo"
+	"github.com/asstart/go-session/storetest"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoURIEnv is environment variable with connection string of MongoDB
+// used by tests, tests are skipped when it isn't set
+const mongoURIEnv = "SESSION_TEST_MONGO_URI"
+
+func testCollection(t *testing.T) *mongo.Collection {
+	uri := os.Getenv(mongoURIEnv)
+	if uri == "" {
+		t.Skipf("%v isn't set", mongoURIEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.Nil(t, err)
+
+	coll := client.Database("session_test").Collection(t.Name())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = coll.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	return coll
+}
+
+func TestMongoStoreSuite(t *testing.T) {
+	coll := testCollection(t)
+	require.Nil(t, smongo.EnsureIndexes(context.Background(), coll))
+
+	storetest.RunStoreSuite(t, func() session.Store {
+		return smongo.NewMongoStore(coll, logr.Discard(), "key")
+	})
+}
+
+func TestMongoStoreSuiteWithClock(t *testing.T) {
+	coll := testCollection(t)
+	require.Nil(t, smongo.EnsureIndexes(context.Background(), coll))
+
+	// clock starts at system time, so sessions aren't removed by TTL index during the test
+	clock := storetest.NewFakeClock(time.Now())
+	storetest.RunStoreSuiteWithClock(t, clock, func() session.Store {
+		return smongo.NewMongoStore(coll, logr.Discard(), "key", smongo.WithClock(clock))
+	})
+}
+
+func TestMongoStoreSuiteWithTouchCoalescer(t *testing.T) {
+	coll := testCollection(t)
+	require.Nil(t, smongo.EnsureIndexes(context.Background(), coll))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc := smongo.NewTouchCoalescer(ctx, coll, logr.Discard(), smongo.WithFlushInterval(10*time.Millisecond))
+
+	storetest.RunStoreSuite(t, func() session.Store {
+		return smongo.NewMongoStore(coll, logr.Discard(), "key", smongo.WithTouchCoalescer(tc))
+	})
+}
+
+func TestTouchThreshold(t *testing.T) {
+	coll := testCollection(t)
+	ctx := context.Background()
+	clock := storetest.NewFakeClock(time.Now())
+	store := smongo.NewMongoStore(coll, logr.Discard(), "key", smongo.WithClock(clock), smongo.WithTouchThreshold(0.5))
+
+	s, err := session.NewSession()
+	require.Nil(t, err)
+	s.IdleTimeout = time.Hour
+	saved, err := store.Save(ctx, &s)
+	require.Nil(t, err)
+
+	clock.Advance(29 * time.Minute)
+	loaded, err := store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	require.True(t, saved.LastAccessedAt.Equal(loaded.LastAccessedAt))
+
+	clock.Advance(2 * time.Minute)
+	loaded, err = store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	require.WithinDuration(t, clock.Now(), loaded.LastAccessedAt, time.Millisecond)
+
+	peeked, err := store.Peek(ctx, s.ID)
+	require.Nil(t, err)
+	require.True(t, loaded.LastAccessedAt.Equal(peeked.LastAccessedAt))
+}
+
+func TestTouchCoalescer(t *testing.T) {
+	coll := testCollection(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	tc := smongo.NewTouchCoalescer(ctx, coll, logr.Discard(), smongo.WithFlushInterval(time.Hour))
+	store := smongo.NewMongoStore(coll, logr.Discard(), "key", smongo.WithTouchCoalescer(tc))
+
+	s, err := session.NewSession()
+	require.Nil(t, err)
+	saved, err := store.Save(ctx, &s)
+	require.Nil(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	loaded, err := store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	require.True(t, loaded.LastAccessedAt.After(saved.LastAccessedAt))
+
+	peeked, err := store.Peek(ctx, s.ID)
+	require.Nil(t, err)
+	require.True(t, saved.LastAccessedAt.Equal(peeked.LastAccessedAt))
+
+	cancel()
+	<-tc.Done()
+
+	peeked, err = store.Peek(context.Background(), s.ID)
+	require.Nil(t, err)
+	require.True(t, loaded.LastAccessedAt.Equal(peeked.LastAccessedAt))
+}
+
+func TestEnsureIndexes(t *testing.T) {
+	coll := testCollection(t)
+	ctx := context.Background()
+
+	require.Nil(t, smongo.EnsureIndexes(ctx, coll))
+	// must be idempotent
+	require.Nil(t, smongo.EnsureIndexes(ctx, coll))
+
+	specs, err := coll.Indexes().ListSpecifications(ctx)
+	require.Nil(t, err)
+
+	byName := map[string]*mongo.IndexSpecification{}
+	for _, s := range specs {
+		byName[s.Name] = s
+	}
+	require.Contains(t, byName, "sid")
+	require.NotNil(t, byName["sid"].Unique)
+	require.True(t, *byName["sid"].Unique)
+	require.Contains(t, byName, "uid_active")
+	require.Contains(t, byName, "expires_at_ttl")
+	require.NotNil(t, byName["expires_at_ttl"].ExpireAfterSeconds)
+	require.Equal(t, int32(0), *byName["expires_at_ttl"].ExpireAfterSeconds)
+}
+
+func TestExpiresAtMaintained(t *testing.T) {
+	coll := testCollection(t)
+	ctx := context.Background()
+	store := smongo.NewMongoStore(coll, logr.Discard(), "key")
+
+	s, err := session.NewSession()
+	require.Nil(t, err)
+	s.IdleTimeout = time.Minute
+	s.AbsTimeout = time.Hour
+
+	saved, err := store.Save(ctx, &s)
+	require.Nil(t, err)
+
+	var doc struct {
+		ExpiresAt time.Time `bson:"expires_at"`
+	}
+	require.Nil(t, coll.FindOne(ctx, bson.D{{"sid", s.ID}}).Decode(&doc))
+	require.WithinDuration(t, saved.LastAccessedAt.Add(s.IdleTimeout), doc.ExpiresAt, time.Second)
+
+	require.Nil(t, store.Invalidate(ctx, s.ID))
+	require.Nil(t, coll.FindOne(ctx, bson.D{{"sid", s.ID}}).Decode(&doc))
+	require.False(t, doc.ExpiresAt.IsZero())
+}
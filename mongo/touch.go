@@ -0,0 +1,169 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/go-logr/logr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultTouchFlushInterval = time.Second
+	defaultTouchMaxBatch      = 1000
+	// finalFlushTimeout limit writing of touches left when coalescer is stopped,
+	// its context is already done then
+	finalFlushTimeout = 5 * time.Second
+)
+
+// TouchCoalescer collect touches of loaded sessions and write them in background
+// with a single BulkWrite per batch, touches of the same session are merged keeping the latest.
+//
+// Touches are kept in memory until flushed, if the process crashes they are lost
+// and sessions may expire up to FlushInterval earlier.
+type TouchCoalescer struct {
+	mu      sync.Mutex
+	pending map[string]time.Time
+	full    chan struct{}
+	done    chan struct{}
+
+	Collection    *mongo.Collection
+	Logger        logr.Logger
+	FlushInterval time.Duration
+	MaxBatch      int
+}
+
+// CoalescerOption configures coalescer created by NewTouchCoalescer
+type CoalescerOption func(*TouchCoalescer)
+
+// WithFlushInterval set how often pending touches are written, one second by default
+func WithFlushInterval(d time.Duration) CoalescerOption {
+	return func(tc *TouchCoalescer) {
+		tc.FlushInterval = d
+	}
+}
+
+// WithMaxBatch set number of pending touches which triggers flush before FlushInterval is passed,
+// 1000 by default. Zero or negative value disables early flushes
+func WithMaxBatch(n int) CoalescerOption {
+	return func(tc *TouchCoalescer) {
+		tc.MaxBatch = n
+	}
+}
+
+/*
+NewTouchCoalescer Create coalescer writing touches to session collection c,
+it's passed to the store with WithTouchCoalescer.
+
+Pending touches are written every FlushInterval until ctx is done,
+then the rest is written and channel returned by Done is closed.
+*/
+func NewTouchCoalescer(ctx context.Context, c *mongo.Collection, l logr.Logger, opts ...CoalescerOption) *TouchCoalescer {
+	tc := &TouchCoalescer{
+		pending:       make(map[string]time.Time),
+		full:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		Collection:    c,
+		Logger:        l,
+		FlushInterval: defaultTouchFlushInterval,
+		MaxBatch:      defaultTouchMaxBatch,
+	}
+	for _, o := range opts {
+		o(tc)
+	}
+
+	go tc.run(ctx)
+
+	return tc
+}
+
+// Touch schedule bump of last_accessed_at of session sid to at,
+// session isn't touched if it isn't live at that time or it's accessed later
+func (tc *TouchCoalescer) Touch(sid string, at time.Time) {
+	tc.mu.Lock()
+	if prev, ok := tc.pending[sid]; !ok || at.After(prev) {
+		tc.pending[sid] = at
+	}
+	n := len(tc.pending)
+	tc.mu.Unlock()
+
+	if tc.MaxBatch > 0 && n >= tc.MaxBatch {
+		select {
+		case tc.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush write pending touches now
+func (tc *TouchCoalescer) Flush(ctx context.Context) error {
+	tc.mu.Lock()
+	pending := tc.pending
+	tc.pending = make(map[string]time.Time)
+	tc.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	opts := options.BulkWrite()
+	opts = opts.SetOrdered(false)
+
+	_, err := tc.Collection.BulkWrite(ctx, touchModels(pending), opts)
+	if err != nil {
+		err = fmt.Errorf("session.mongo.TouchCoalescer.Flush() BulkWrite() unexpected error: %w", err)
+		tc.Logger.V(0).Info("session.mongo.TouchCoalescer.Flush() BulkWrite() unexpected error",
+			"session.touches", len(pending),
+			session.LogKeyDebugError, err)
+		return err
+	}
+
+	tc.Logger.V(0).Info("session.mongo.TouchCoalescer.Flush() finished", "session.touches", len(pending))
+
+	return nil
+}
+
+// Done return channel which is closed when coalescer is stopped and the last touches are written
+func (tc *TouchCoalescer) Done() <-chan struct{} {
+	return tc.done
+}
+
+func (tc *TouchCoalescer) run(ctx context.Context) {
+	defer close(tc.done)
+
+	t := time.NewTicker(tc.FlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fctx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			_ = tc.Flush(fctx)
+			cancel()
+			return
+		case <-t.C:
+		case <-tc.full:
+		}
+		_ = tc.Flush(ctx)
+	}
+}
+
+// touchModels return updates bumping last_accessed_at of sessions which were live at the time of access,
+// last_accessed_at is never moved back
+func touchModels(pending map[string]time.Time) []mongo.WriteModel {
+	models := make([]mongo.WriteModel, 0, len(pending))
+	for sid, at := range pending {
+		f := append(liveFilterAt(sid, literal(at)), bson.E{"last_accessed_at", bson.D{{"$lt", at}}})
+		upd := bson.A{
+			bson.D{{"$set", bson.D{{"last_accessed_at", literal(at)}}}},
+			expiresAtStage(),
+		}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(f).SetUpdate(upd))
+	}
+	return models
+}
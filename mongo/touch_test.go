@@ -0,0 +1,61 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newTestCoalescer(maxBatch int) *TouchCoalescer {
+	return &TouchCoalescer{
+		pending:  make(map[string]time.Time),
+		full:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		MaxBatch: maxBatch,
+	}
+}
+
+func TestTouchKeepsLatest(t *testing.T) {
+	tc := newTestCoalescer(0)
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tc.Touch("sid1", now)
+	tc.Touch("sid1", now.Add(time.Second))
+	tc.Touch("sid1", now.Add(-time.Second))
+	tc.Touch("sid2", now)
+
+	assert.Equal(t, map[string]time.Time{
+		"sid1": now.Add(time.Second),
+		"sid2": now,
+	}, tc.pending)
+	assert.Len(t, tc.full, 0)
+}
+
+func TestTouchSignalsFullBatch(t *testing.T) {
+	tc := newTestCoalescer(2)
+	now := time.Now()
+
+	tc.Touch("sid1", now)
+	tc.Touch("sid1", now)
+	assert.Len(t, tc.full, 0)
+
+	tc.Touch("sid2", now)
+	tc.Touch("sid3", now)
+	assert.Len(t, tc.full, 1)
+}
+
+func TestTouchModels(t *testing.T) {
+	at := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	models := touchModels(map[string]time.Time{"sid": at})
+	assert.Len(t, models, 1)
+
+	m := models[0].(*mongo.UpdateOneModel)
+	f := m.Filter.(bson.D)
+	assert.Equal(t, bson.E{"sid", "sid"}, f[0])
+	assert.Equal(t, bson.E{"last_accessed_at", bson.D{{"$lt", at}}}, f[len(f)-1])
+	assert.Equal(t, bson.D{{"$set", bson.D{{"last_accessed_at", literal(at)}}}}, m.Update.(bson.A)[0])
+}
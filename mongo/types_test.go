@@ -0,0 +1,152 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type testCart struct {
+	Items []string
+	Total float64
+}
+
+func init() {
+	session.RegisterType[testCart]("mongo.testCart")
+}
+
+// roundTrip encode session data the way the store writes it and decode it the way the store reads it
+func roundTrip(t *testing.T, data map[string]interface{}) session.Session {
+	raw, err := bson.Marshal(bson.D{
+		{"sid", "sid"},
+		{"data", data},
+		{"types", toMngAttrTypes(session.DataTypes(data))},
+	})
+	require.Nil(t, err)
+
+	var ms mngSession
+	require.Nil(t, bson.UnmarshalWithRegistry(getCustomRegisry(), raw, &ms))
+	return fromMngSession(&ms)
+}
+
+func TestAttributeTypesRoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	s := roundTrip(t, map[string]interface{}{
+		"int":          1,
+		"int64":        int64(1) << 40,
+		"float32":      float32(1.5),
+		"float64":      2.5,
+		"string":       "v",
+		"bool":         true,
+		"time":         now,
+		"[]interface":  []interface{}{"a", int32(1)},
+		"[]int32":      []int32{1, 2},
+		"[]int64":      []int64{1, 2},
+		"[]float32":    []float32{1.5},
+		"[]float64":    []float64{2.5},
+		"[]string":     []string{"a", "b"},
+		"[]bool":       []bool{true, false},
+		"[]time":       []time.Time{now},
+		"cart":         testCart{Items: []string{"a"}, Total: 1.5},
+		"nested":       map[string]interface{}{"[]string": []string{"c"}, "int": 2},
+		"unregistered": struct{ Name string }{"n"},
+	})
+
+	i, ok := s.GetInt("int")
+	assert.True(t, ok)
+	assert.Equal(t, 1, i)
+
+	i64, ok := s.GetInt64("int64")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1)<<40, i64)
+
+	f32, ok := s.GetFloat32("float32")
+	assert.True(t, ok)
+	assert.Equal(t, float32(1.5), f32)
+
+	f64, ok := s.GetFloat64("float64")
+	assert.True(t, ok)
+	assert.Equal(t, 2.5, f64)
+
+	str, ok := s.GetString("string")
+	assert.True(t, ok)
+	assert.Equal(t, "v", str)
+
+	b, ok := s.GetBool("bool")
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	tm, ok := s.GetTime("time")
+	assert.True(t, ok)
+	assert.True(t, now.Equal(tm))
+
+	sl, ok := s.GetSlice("[]interface")
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"a", int32(1)}, sl)
+
+	i32s, ok := s.GetInt32Slice("[]int32")
+	assert.True(t, ok)
+	assert.Equal(t, []int32{1, 2}, i32s)
+
+	i64s, ok := s.GetInt64Slice("[]int64")
+	assert.True(t, ok)
+	assert.Equal(t, []int64{1, 2}, i64s)
+
+	f32s, ok := s.GetFloat32Slice("[]float32")
+	assert.True(t, ok)
+	assert.Equal(t, []float32{1.5}, f32s)
+
+	f64s, ok := s.GetFloat64Slice("[]float64")
+	assert.True(t, ok)
+	assert.Equal(t, []float64{2.5}, f64s)
+
+	strs, ok := s.GetStringSlice("[]string")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, strs)
+
+	bs, ok := s.GetBoolSlice("[]bool")
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, false}, bs)
+
+	tms, ok := s.GetTimeSlice("[]time")
+	assert.True(t, ok)
+	require.Len(t, tms, 1)
+	assert.True(t, now.Equal(tms[0]))
+
+	cart, ok := s.GetAttribute("cart")
+	assert.True(t, ok)
+	assert.Equal(t, testCart{Items: []string{"a"}, Total: 1.5}, cart)
+
+	nested, ok := s.GetStringSlice("nested.[]string")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"c"}, nested)
+
+	ni, ok := s.GetInt("nested.int")
+	assert.True(t, ok)
+	assert.Equal(t, 2, ni)
+
+	// values of not registered types are read back as maps
+	var unregistered struct{ Name string }
+	assert.True(t, s.GetStruct("unregistered", &unregistered))
+	assert.Equal(t, "n", unregistered.Name)
+}
+
+func TestAttributeTypesNotConvertible(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{
+		{"sid", "sid"},
+		{"data", bson.D{{"k", "v"}}},
+		{"types", []mngAttrType{{Path: "k", Type: "int"}, {Path: "missing", Type: "int"}, {Path: "k2", Type: "unknown"}}},
+	})
+	require.Nil(t, err)
+
+	var ms mngSession
+	require.Nil(t, bson.UnmarshalWithRegistry(getCustomRegisry(), raw, &ms))
+	s := fromMngSession(&ms)
+
+	assert.Equal(t, map[string]interface{}{"k": "v"}, s.Data)
+}
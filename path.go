@@ -0,0 +1,88 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AttributePathSeparator separates segments of nested attribute path,
+// e.g. "cart.items" is attribute "items" of the map kept in attribute "cart"
+const AttributePathSeparator = "."
+
+// SplitAttributePath return segments of attribute path
+func SplitAttributePath(key string) []string {
+	return strings.Split(key, AttributePathSeparator)
+}
+
+// ValidateAttributeKey check that key is a valid attribute path:
+// it consists of non empty segments which don't contain "$" and NUL,
+// so keys can't be interpreted as operators by stores
+func ValidateAttributeKey(key string) error {
+	for _, seg := range SplitAttributePath(key) {
+		if seg == "" || strings.ContainsAny(seg, "$\x00") {
+			return fmt.Errorf("%w: %q", ErrInvalidAttributeKey, key)
+		}
+	}
+	return nil
+}
+
+// ValidateAttributeKeys check that every key is valid (see ValidateAttributeKey)
+// and keys don't overlap, e.g. "cart" and "cart.items" can't be changed by a single call
+func ValidateAttributeKeys(keys ...string) error {
+	for _, k := range keys {
+		err := ValidateAttributeKey(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		if cur == prev || strings.HasPrefix(cur, prev+AttributePathSeparator) {
+			return fmt.Errorf("%w: %q overlaps with %q", ErrInvalidAttributeKey, cur, prev)
+		}
+	}
+	return nil
+}
+
+// getPath return value by attribute path, only map[string]interface{} values are walked through
+func getPath(data map[string]interface{}, segs []string) (interface{}, bool) {
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := data[seg].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		data = next
+	}
+	v, ok := data[segs[len(segs)-1]]
+	return v, ok
+}
+
+// setPath set value by attribute path, missing maps are created
+// and values which aren't map[string]interface{} are replaced by maps
+func setPath(data map[string]interface{}, segs []string, v interface{}) {
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := data[seg].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			data[seg] = next
+		}
+		data = next
+	}
+	data[segs[len(segs)-1]] = v
+}
+
+// removePath remove value by attribute path, it's no-op if there is no such path
+func removePath(data map[string]interface{}, segs []string) {
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := data[seg].(map[string]interface{})
+		if !ok {
+			return
+		}
+		data = next
+	}
+	delete(data, segs[len(segs)-1])
+}
@@ -0,0 +1,94 @@
+package session_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/asstart/go-session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAttributeKeys(t *testing.T) {
+	tt := []struct {
+		name  string
+		keys  []string
+		valid bool
+	}{
+		{"single key", []string{"cart"}, true},
+		{"nested key", []string{"cart.items"}, true},
+		{"siblings", []string{"cart.items", "cart.count", "user"}, true},
+		{"common prefix isn't overlap", []string{"cart", "carts"}, true},
+		{"empty key", []string{""}, false},
+		{"leading separator", []string{".cart"}, false},
+		{"trailing separator", []string{"cart."}, false},
+		{"empty segment", []string{"cart..items"}, false},
+		{"operator", []string{"$set"}, false},
+		{"operator in segment", []string{"cart.$inc"}, false},
+		{"dollar inside segment", []string{"ca$rt"}, false},
+		{"nul", []string{"ca\x00rt"}, false},
+		{"duplicate", []string{"cart", "cart"}, false},
+		{"parent and child", []string{"cart.items", "cart"}, false},
+		{"deep parent and child", []string{"a.b", "x", "a.b.c.d"}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := session.ValidateAttributeKeys(tc.keys...)
+			if tc.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.True(t, errors.Is(err, session.ErrInvalidAttributeKey))
+			}
+		})
+	}
+}
+
+func TestNestedAttributes(t *testing.T) {
+	s := session.Session{}
+
+	s.AddAttribute("cart.count", 2)
+	s.AddAttribute("cart.items", []string{"a", "b"})
+	s.AddAttribute("user.name", "name")
+
+	assert.Equal(t, map[string]interface{}{
+		"cart": map[string]interface{}{
+			"count": 2,
+			"items": []string{"a", "b"},
+		},
+		"user": map[string]interface{}{
+			"name": "name",
+		},
+	}, s.Data)
+
+	count, ok := s.GetInt("cart.count")
+	assert.True(t, ok)
+	assert.Equal(t, 2, count)
+
+	items, ok := s.GetStringSlice("cart.items")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, items)
+
+	_, ok = s.GetAttribute("cart.missing")
+	assert.False(t, ok)
+
+	_, ok = s.GetAttribute("user.name.first")
+	assert.False(t, ok)
+
+	s.RemoveAttribute("cart.count")
+	s.RemoveAttribute("user.name.first")
+	s.RemoveAttribute("missing.key")
+	assert.Equal(t, map[string]interface{}{
+		"cart": map[string]interface{}{
+			"items": []string{"a", "b"},
+		},
+		"user": map[string]interface{}{
+			"name": "name",
+		},
+	}, s.Data)
+
+	// values which aren't maps are replaced
+	s.AddAttribute("user.name.first", "first")
+	first, ok := s.GetString("user.name.first")
+	assert.True(t, ok)
+	assert.Equal(t, "first", first)
+}
@@ -4,31 +4,102 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 )
 
 const (
 	LogKeySID        = "session.sid"
+	LogKeyUID        = "session.uid"
 	LogKeyRQID       = "session.rqud"
 	LogKeyDebugError = "session.dbg_error"
 )
 
-var ErrSessionNotFound = errors.New("sessionservice: session not found")
+var (
+	ErrSessionNotFound     = errors.New("sessionservice: session not found")
+	ErrSessionExpired      = errors.New("sessionservice: session expired")
+	ErrSessionInvalidated  = errors.New("sessionservice: session invalidated")
+	ErrSessionExists       = errors.New("sessionservice: session already exists")
+	ErrVersionConflict     = errors.New("sessionservice: session version conflict")
+	ErrAttributeType       = errors.New("sessionservice: attribute has incompatible type")
+	ErrInvalidAttributeKey = errors.New("sessionservice: invalid attribute key")
+	ErrNotSupported        = errors.New("sessionservice: operation isn't supported by the store")
+)
+
+// newSIDAttempts is how many session ids are generated
+// before giving up when they collide with existing sessions
+const newSIDAttempts = 3
+
+// modifyAttempts is how many times ModifySession retries on version conflict
+const modifyAttempts = 5
 
 type Service interface {
 	CreateAnonymSession(ctx context.Context, cc CookieConf, sc Conf, keyAndValues ...interface{}) (*Session, error)
 	CreateUserSession(ctx context.Context, uid string, cc CookieConf, sc Conf, keyAndValues ...interface{}) (*Session, error)
 	LoadSession(ctx context.Context, sid string) (*Session, error)
+	PeekSession(ctx context.Context, sid string) (*Session, error)
+	SessionExists(ctx context.Context, sid string) (bool, error)
 	InvalidateSession(ctx context.Context, sid string) error
 	AddAttributes(ctx context.Context, sid string, keyAndValues ...interface{}) (*Session, error)
 	RemoveAttributes(ctx context.Context, sid string, keys ...string) (*Session, error)
+	IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*Session, error)
+	AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*Session, error)
+	UpdateSession(ctx context.Context, s *Session) (*Session, error)
+	ModifySession(ctx context.Context, sid string, fn func(*Session) error) (*Session, error)
+	RegenerateSession(ctx context.Context, sid string) (*Session, error)
+	PromoteToUser(ctx context.Context, sid string, uid string, opts ...PromoteOption) (*Session, error)
+	ListUserSessions(ctx context.Context, uid string) ([]*Session, error)
+	InvalidateUserSessions(ctx context.Context, uid string, exceptSID ...string) error
 }
 
 type sessionService struct {
-	Logger      logr.Logger
-	SStore      Store
-	CtxReqIDKey interface{}
+	Logger          logr.Logger
+	SStore          Store
+	CtxReqIDKey     interface{}
+	RegenerateGrace time.Duration
+	Signer          *Signer
+	IDGenerator     IDGenerator
+	Clock           Clock
+}
+
+// ServiceOption configures Service created by NewService
+type ServiceOption func(*sessionService)
+
+// WithRegenerateGrace set how long an old session id stays valid after RegenerateSession,
+// so concurrent in-flight requests with the old cookie don't fail.
+// By default old session id is invalidated immediately
+func WithRegenerateGrace(d time.Duration) ServiceOption {
+	return func(ss *sessionService) {
+		ss.RegenerateGrace = d
+	}
+}
+
+// WithSigner make the service sign ids of returned sessions (see Signer),
+// ids passed to the service should be signed, ids with invalid signature
+// are rejected with ErrSessionNotFound without touching the store
+func WithSigner(sg *Signer) ServiceOption {
+	return func(ss *sessionService) {
+		ss.Signer = sg
+	}
+}
+
+// WithIDGenerator set how ids of new sessions are generated,
+// NewBase32IDGenerator is used by default
+func WithIDGenerator(g IDGenerator) ServiceOption {
+	return func(ss *sessionService) {
+		ss.IDGenerator = g
+	}
+}
+
+// WithServiceClock make the service check expiry of sessions returned by the store at the clock time,
+// so sessions created, loaded, updated and regenerated are live in application time
+// even if the store keeps its own time (e.g. Mongo server time).
+// By default expiry checks of the store are trusted
+func WithServiceClock(c Clock) ServiceOption {
+	return func(ss *sessionService) {
+		ss.Clock = c
+	}
 }
 
 /*
@@ -41,11 +112,21 @@ to decide what is really error in terms of application and what is not.
 
 reqIDKey is key to extract request id from the context
 */
-func NewService(s Store, l logr.Logger, reqIDKey interface{}) Service {
+func NewService(s Store, l logr.Logger, reqIDKey interface{}, opts ...ServiceOption) Service {
 	ss := sessionService{
 		Logger:      l,
 		SStore:      s,
 		CtxReqIDKey: reqIDKey,
+		IDGenerator: defaultIDGenerator,
+	}
+	for _, o := range opts {
+		o(&ss)
+	}
+	if ss.Clock != nil {
+		ss.SStore = &clockedStore{Store: ss.SStore, clock: ss.Clock}
+	}
+	if ss.Signer != nil {
+		ss.SStore = &signedStore{Store: ss.SStore, signer: ss.Signer}
 	}
 	return &ss
 }
@@ -62,7 +143,7 @@ func (ss *sessionService) CreateAnonymSession(ctx context.Context, cc CookieConf
 
 	data, err := parseAttrs(keyAndValues...)
 	if err != nil {
-		err = fmt.Errorf("session.CreateAnonymSession() error: %v", err)
+		err = fmt.Errorf("session.CreateAnonymSession() error: %w", err)
 		ss.Logger.V(0).Info(
 			"session.CreateAnonymSession() error",
 			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
@@ -71,7 +152,7 @@ func (ss *sessionService) CreateAnonymSession(ctx context.Context, cc CookieConf
 		return nil, err
 	}
 
-	s, err := NewSession()
+	sid, err := ss.IDGenerator.Generate()
 	if err != nil {
 		err = fmt.Errorf("session.CreateAnonymSession() error creating anon session: %w", err)
 		ss.Logger.V(0).Info(
@@ -81,13 +162,14 @@ func (ss *sessionService) CreateAnonymSession(ctx context.Context, cc CookieConf
 		return nil, err
 	}
 
+	s := newSessionWithID(sid)
 	s.WithCookieConf(cc)
 	s.WithSessionConf(sc)
 	s.WithAttributes(data)
 
-	svdS, err := ss.SStore.Save(ctx, &s)
+	svdS, err := ss.create(ctx, &s)
 	if err != nil {
-		err = fmt.Errorf("session.CreateAnonymSession() Save error: %w", err)
+		err = fmt.Errorf("session.CreateAnonymSession() Create error: %w", err)
 		ss.Logger.V(0).Info(
 			"session.CreateAnonymSession() error",
 			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
@@ -115,7 +197,7 @@ func (ss *sessionService) CreateUserSession(ctx context.Context, uid string, cc
 		return nil, err
 	}
 
-	s, err := NewSession()
+	sid, err := ss.IDGenerator.Generate()
 	if err != nil {
 		err = fmt.Errorf("session.CreateUserSession() error creating user session: %w", err)
 		ss.Logger.V(0).Info(
@@ -125,14 +207,15 @@ func (ss *sessionService) CreateUserSession(ctx context.Context, uid string, cc
 		return nil, err
 	}
 
+	s := newSessionWithID(sid)
 	s.WithCookieConf(cc)
 	s.WithUserID(uid)
 	s.WithSessionConf(sc)
 	s.WithAttributes(data)
 
-	svdS, err := ss.SStore.Save(ctx, &s)
+	svdS, err := ss.create(ctx, &s)
 	if err != nil {
-		err = fmt.Errorf("session.CreateUserSession() Save error: %w", err)
+		err = fmt.Errorf("session.CreateUserSession() Create error: %w", err)
 		ss.Logger.V(0).Info(
 			"session.CreateUserSession() error",
 			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
@@ -144,14 +227,16 @@ func (ss *sessionService) CreateUserSession(ctx context.Context, uid string, cc
 }
 
 // LoadSession return session loaded from storage based on implementation of Store
+// return ErrSessionNotFound, ErrSessionExpired or ErrSessionInvalidated
+// if there is no live session with such id
 func (ss *sessionService) LoadSession(ctx context.Context, sid string) (*Session, error) {
 	ss.Logger.V(0).Info("session.LoadSession() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
 	defer ss.Logger.V(0).Info("session.LoadSession() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
 
 	s, err := ss.SStore.Load(ctx, sid)
 
-	if err == ErrSessionNotFound {
-		return nil, ErrSessionNotFound
+	if isSessionStateErr(err) {
+		return nil, err
 	}
 
 	if err != nil {
@@ -166,6 +251,54 @@ func (ss *sessionService) LoadSession(ctx context.Context, sid string) (*Session
 	return s, nil
 }
 
+// PeekSession load session without extending its idle timeout,
+// it's meant for admin tools and background jobs inspecting sessions of others
+func (ss *sessionService) PeekSession(ctx context.Context, sid string) (*Session, error) {
+	ss.Logger.V(0).Info("session.PeekSession() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.PeekSession() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	s, err := ss.SStore.Peek(ctx, sid)
+
+	if isSessionStateErr(err) {
+		return nil, err
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.PeekSession() Peek error: %w", err)
+		ss.Logger.V(0).Info(
+			"session.PeekSession() error",
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SessionExists check if session is live without loading its data and extending its idle timeout
+func (ss *sessionService) SessionExists(ctx context.Context, sid string) (bool, error) {
+	ss.Logger.V(0).Info("session.SessionExists() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.SessionExists() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	ok, err := ss.SStore.Exists(ctx, sid)
+	if err != nil {
+		err = fmt.Errorf("session.SessionExists() Exists error: %w", err)
+		ss.Logger.V(0).Info(
+			"session.SessionExists() error",
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// ValidateSessionID validate format of session id with the service IDGenerator,
+// sid is expected without signature
+func (ss *sessionService) ValidateSessionID(sid string) error {
+	return ss.IDGenerator.Validate(sid)
+}
+
 // InvalidateSession invalidate session in storage based on implementation of Store
 func (ss *sessionService) InvalidateSession(ctx context.Context, sid string) error {
 	ss.Logger.V(0).Info("session.InvalidateSession() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
@@ -200,7 +333,7 @@ func (ss *sessionService) AddAttributes(ctx context.Context, sid string, keyAndV
 
 	data, err := parseAttrs(keyAndValues...)
 	if err != nil {
-		err = fmt.Errorf("session.AddAttributes() error: %v", err)
+		err = fmt.Errorf("session.AddAttributes() error: %w", err)
 		ss.Logger.V(0).Info(
 			"session.AddAttributes() error",
 			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
@@ -212,8 +345,8 @@ func (ss *sessionService) AddAttributes(ctx context.Context, sid string, keyAndV
 
 	s, err := ss.SStore.AddAttributes(ctx, sid, data)
 
-	if err == ErrSessionNotFound {
-		return nil, ErrSessionNotFound
+	if isSessionStateErr(err) {
+		return nil, err
 	}
 
 	if err != nil {
@@ -243,9 +376,21 @@ func (ss *sessionService) RemoveAttributes(ctx context.Context, sid string, keys
 		return nil, err
 	}
 
+	err := ValidateAttributeKeys(keys...)
+	if err != nil {
+		err = fmt.Errorf("session.RemoveAttributes() error: %w", err)
+		ss.Logger.V(0).Info(
+			"session.RemoveAttributes() error",
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err,
+			LogKeySID, sid,
+		)
+		return nil, err
+	}
+
 	s, err := ss.SStore.RemoveAttributes(ctx, sid, keys...)
-	if err == ErrSessionNotFound {
-		return nil, ErrSessionNotFound
+	if isSessionStateErr(err) {
+		return nil, err
 	}
 
 	if err != nil {
@@ -260,6 +405,353 @@ func (ss *sessionService) RemoveAttributes(ctx context.Context, sid string, keys
 	return s, nil
 }
 
+// IncrementAttribute atomically add delta to numeric attribute, missing attribute is considered to be 0.
+// It's supposed to be used for counters, e.g. failed login attempts.
+// Return ErrAttributeType if the attribute isn't a number
+func (ss *sessionService) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*Session, error) {
+	ss.Logger.V(0).Info("session.IncrementAttribute() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.IncrementAttribute() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	err := ValidateAttributeKey(key)
+	if err != nil {
+		err = fmt.Errorf("session.IncrementAttribute() error: %w", err)
+		ss.Logger.V(0).Info(
+			"session.IncrementAttribute() error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := ss.SStore.IncrementAttribute(ctx, sid, key, delta)
+	if isSessionStateErr(err) || errors.Is(err, ErrAttributeType) {
+		return nil, err
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.IncrementAttribute() IncrementAttribute unexpected error: %w", err)
+		ss.Logger.V(0).Info("session.IncrementAttribute() IncrementAttribute unexpected error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// AppendToAttribute atomically append values to list attribute, missing attribute is considered to be empty list.
+// If maxLen > 0 only last maxLen elements are kept, e.g. for "recently viewed" lists.
+// Return ErrAttributeType if the attribute isn't a list
+func (ss *sessionService) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*Session, error) {
+	ss.Logger.V(0).Info("session.AppendToAttribute() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.AppendToAttribute() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	if len(values) == 0 {
+		err := fmt.Errorf("session.AppendToAttribute() no values to append")
+		ss.Logger.V(0).Info(
+			"session.AppendToAttribute() error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	err := ValidateAttributeKey(key)
+	if err != nil {
+		err = fmt.Errorf("session.AppendToAttribute() error: %w", err)
+		ss.Logger.V(0).Info(
+			"session.AppendToAttribute() error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	s, err := ss.SStore.AppendToAttribute(ctx, sid, key, values, maxLen)
+	if isSessionStateErr(err) || errors.Is(err, ErrAttributeType) {
+		return nil, err
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.AppendToAttribute() AppendToAttribute unexpected error: %w", err)
+		ss.Logger.V(0).Info("session.AppendToAttribute() AppendToAttribute unexpected error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// UpdateSession replace cookie settings, timeouts, user binding and data of the stored session with values of s,
+// CreatedAt and LastAccessedAt of s are ignored.
+// Return ErrVersionConflict if the session was changed since s was loaded (see Session.Version),
+// ErrSessionNotFound, ErrSessionExpired or ErrSessionInvalidated
+// if the session was invalidated or expired in between, such session isn't revived
+func (ss *sessionService) UpdateSession(ctx context.Context, s *Session) (*Session, error) {
+	if s == nil {
+		err := fmt.Errorf("session.UpdateSession() nil session")
+		ss.Logger.V(0).Info(
+			"session.UpdateSession() error",
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	ss.Logger.V(0).Info("session.UpdateSession() started", LogKeySID, s.ID, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.UpdateSession() finished", LogKeySID, s.ID, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	updS, err := ss.SStore.Update(ctx, s)
+	if isSessionStateErr(err) || errors.Is(err, ErrVersionConflict) {
+		return nil, err
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.UpdateSession() Update unexpected error: %w", err)
+		ss.Logger.V(0).Info("session.UpdateSession() Update unexpected error",
+			LogKeySID, s.ID,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return updS, nil
+}
+
+// ModifySession load session, apply fn to it and store the result with UpdateSession.
+// If the session was changed concurrently it's loaded and fn is applied again,
+// so fn may be called several times and shouldn't have side effects.
+// Error returned by fn is returned as is and the session isn't updated.
+// Return ErrVersionConflict if the session is still changed concurrently after several attempts
+func (ss *sessionService) ModifySession(ctx context.Context, sid string, fn func(*Session) error) (*Session, error) {
+	ss.Logger.V(0).Info("session.ModifySession() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.ModifySession() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	for i := 1; ; i++ {
+		s, err := ss.LoadSession(ctx, sid)
+		if err != nil {
+			return nil, err
+		}
+
+		err = fn(s)
+		if err != nil {
+			return nil, err
+		}
+
+		updS, err := ss.UpdateSession(ctx, s)
+		if !errors.Is(err, ErrVersionConflict) || i == modifyAttempts {
+			return updS, err
+		}
+
+		ss.Logger.V(0).Info("session.ModifySession() version conflict", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey), "session.attempt", i)
+	}
+}
+
+// RegenerateSession move session to a new id keeping its data, user and timeouts,
+// old session id is invalidated (see WithRegenerateGrace).
+// It's supposed to be called on login and privilege changes to prevent session fixation
+func (ss *sessionService) RegenerateSession(ctx context.Context, sid string) (*Session, error) {
+	ss.Logger.V(0).Info("session.RegenerateSession() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.RegenerateSession() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	s, err := ss.regenerate(ctx, sid, nil)
+	if isSessionStateErr(err) {
+		return nil, err
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.RegenerateSession() Regenerate unexpected error: %w", err)
+		ss.Logger.V(0).Info("session.RegenerateSession() Regenerate unexpected error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+type promoteConf struct {
+	keepAttrs    bool
+	keyAndValues []interface{}
+	sessionConf  *Conf
+}
+
+// PromoteOption configures PromoteToUser
+type PromoteOption func(*promoteConf)
+
+// PromoteKeepAttributes keep attributes added to the session before login,
+// they are dropped by default
+func PromoteKeepAttributes() PromoteOption {
+	return func(pc *promoteConf) {
+		pc.keepAttrs = true
+	}
+}
+
+// PromoteAttributes add attributes to the promoted session,
+// they override attributes kept with PromoteKeepAttributes
+func PromoteAttributes(keyAndValues ...interface{}) PromoteOption {
+	return func(pc *promoteConf) {
+		pc.keyAndValues = append(pc.keyAndValues, keyAndValues...)
+	}
+}
+
+// PromoteSessionConf reset session timeouts to sc,
+// timeouts of the anonym session are kept by default
+func PromoteSessionConf(sc Conf) PromoteOption {
+	return func(pc *promoteConf) {
+		pc.sessionConf = &sc
+	}
+}
+
+// PromoteToUser bind session to user identity on login.
+// Session is moved to a new id the same way as by RegenerateSession
+// and old session id is invalidated
+func (ss *sessionService) PromoteToUser(ctx context.Context, sid string, uid string, opts ...PromoteOption) (*Session, error) {
+	ss.Logger.V(0).Info("session.PromoteToUser() started", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.PromoteToUser() finished", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	pc := promoteConf{}
+	for _, o := range opts {
+		o(&pc)
+	}
+
+	data, err := parseAttrs(pc.keyAndValues...)
+	if err != nil {
+		err = fmt.Errorf("session.PromoteToUser() error: %w", err)
+		ss.Logger.V(0).Info(
+			"session.PromoteToUser() error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	promote := func(s *Session) {
+		if !pc.keepAttrs {
+			s.Data = make(map[string]interface{})
+		}
+		s.WithAttributes(data)
+		s.WithUserID(uid)
+		if pc.sessionConf != nil {
+			s.WithSessionConf(*pc.sessionConf)
+		}
+	}
+
+	s, err := ss.regenerate(ctx, sid, promote)
+	if isSessionStateErr(err) {
+		return nil, err
+	}
+
+	if err != nil {
+		err = fmt.Errorf("session.PromoteToUser() Regenerate unexpected error: %w", err)
+		ss.Logger.V(0).Info("session.PromoteToUser() Regenerate unexpected error",
+			LogKeySID, sid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ListUserSessions return active sessions of the user, e.g. to show user's devices
+func (ss *sessionService) ListUserSessions(ctx context.Context, uid string) ([]*Session, error) {
+	ss.Logger.V(0).Info("session.ListUserSessions() started", LogKeyUID, uid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.ListUserSessions() finished", LogKeyUID, uid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	if uid == "" {
+		err := fmt.Errorf("session.ListUserSessions() empty uid")
+		ss.Logger.V(0).Info(
+			"session.ListUserSessions() error",
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	sessions, err := ss.SStore.ListUserSessions(ctx, uid)
+	if err != nil {
+		err = fmt.Errorf("session.ListUserSessions() ListUserSessions unexpected error: %w", err)
+		ss.Logger.V(0).Info("session.ListUserSessions() ListUserSessions unexpected error",
+			LogKeyUID, uid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// InvalidateUserSessions invalidate all sessions of the user except exceptSID,
+// e.g. on password change or account compromise ("log out everywhere")
+func (ss *sessionService) InvalidateUserSessions(ctx context.Context, uid string, exceptSID ...string) error {
+	ss.Logger.V(0).Info("session.InvalidateUserSessions() started", LogKeyUID, uid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	defer ss.Logger.V(0).Info("session.InvalidateUserSessions() finished", LogKeyUID, uid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+	if uid == "" {
+		err := fmt.Errorf("session.InvalidateUserSessions() empty uid")
+		ss.Logger.V(0).Info(
+			"session.InvalidateUserSessions() error",
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return err
+	}
+
+	err := ss.SStore.InvalidateUserSessions(ctx, uid, exceptSID...)
+	if err != nil {
+		err = fmt.Errorf("session.InvalidateUserSessions() InvalidateUserSessions unexpected error: %w", err)
+		ss.Logger.V(0).Info("session.InvalidateUserSessions() InvalidateUserSessions unexpected error",
+			LogKeyUID, uid,
+			LogKeyRQID, ctx.Value(ss.CtxReqIDKey),
+			LogKeyDebugError, err)
+		return err
+	}
+
+	return nil
+}
+
+// create store new session, its id is regenerated if it collides with existing session
+func (ss *sessionService) create(ctx context.Context, s *Session) (*Session, error) {
+	for i := 1; ; i++ {
+		svdS, err := ss.SStore.Create(ctx, s)
+		if !errors.Is(err, ErrSessionExists) || i == newSIDAttempts {
+			return svdS, err
+		}
+
+		ss.Logger.V(0).Info("session.create() session id collision", LogKeySID, s.ID, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+
+		s.ID, err = ss.IDGenerator.Generate()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// regenerate move session to a new id, the new id is generated again if it collides with existing session
+func (ss *sessionService) regenerate(ctx context.Context, sid string, modify func(*Session)) (*Session, error) {
+	for i := 1; ; i++ {
+		newSID, err := ss.IDGenerator.Generate()
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := ss.SStore.Regenerate(ctx, sid, newSID, ss.RegenerateGrace, modify)
+		if !errors.Is(err, ErrSessionExists) || i == newSIDAttempts {
+			return s, err
+		}
+
+		ss.Logger.V(0).Info("session.regenerate() session id collision", LogKeySID, sid, LogKeyRQID, ctx.Value(ss.CtxReqIDKey))
+	}
+}
+
+// isSessionStateErr check if err is or wraps one of errors describing
+// that there is no live session, they're returned as is without wrapping
+func isSessionStateErr(err error) bool {
+	return errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired) || errors.Is(err, ErrSessionInvalidated)
+}
+
 func parseAttrs(keyAndValues ...interface{}) (map[string]interface{}, error) {
 	if len(keyAndValues)%2 != 0 {
 		return nil, fmt.Errorf("expected even count of key and values, got: %v", len(keyAndValues))
@@ -274,5 +766,15 @@ func parseAttrs(keyAndValues ...interface{}) (map[string]interface{}, error) {
 		}
 		data[k] = keyAndValues[i+1]
 	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	err := ValidateAttributeKeys(keys...)
+	if err != nil {
+		return nil, err
+	}
+
 	return data, nil
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/asstart/go-session"
 	smocks "github.com/asstart/go-session/mocks"
+	"github.com/asstart/go-session/storetest"
 	"github.com/go-logr/logr"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -77,7 +78,7 @@ func TestCreateAnonSessionValidAttributes(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			retSes := session.Session{Data: tc.expData}
-			smock.EXPECT().Save(gomock.Any(), sesDataMatcher{&retSes}).Return(&retSes, nil)
+			smock.EXPECT().Create(gomock.Any(), sesDataMatcher{&retSes}).Return(&retSes, nil)
 
 			created, err := service.CreateAnonymSession(context.Background(), session.DefaultCookieConf(), session.DefaultSessionConf(), tc.kv...)
 			assert.Nil(t, err)
@@ -151,7 +152,7 @@ func TestCreateUserSessionValidAttributes(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			retSes := session.Session{Data: tc.expData}
-			smock.EXPECT().Save(gomock.Any(), sesDataMatcher{&retSes}).Return(&retSes, nil)
+			smock.EXPECT().Create(gomock.Any(), sesDataMatcher{&retSes}).Return(&retSes, nil)
 
 			created, err := service.CreateUserSession(context.Background(), uid, session.DefaultCookieConf(), session.DefaultSessionConf(), tc.kv...)
 			assert.Nil(t, err)
@@ -238,7 +239,7 @@ func TestSuccessfulCreatingAnonymSession(t *testing.T) {
 
 	resSes := session.Session{}
 
-	smock.EXPECT().Save(ctx, sesFullMatcher{
+	smock.EXPECT().Create(ctx, sesFullMatcher{
 		&session.Session{
 			Active:      true,
 			Anonym:      true,
@@ -269,9 +270,9 @@ func TestErrorSavingAnonymSession(t *testing.T) {
 	)
 
 	retErr := errors.New("some err")
-	expErr := fmt.Errorf("session.CreateAnonymSession() Save error: %w", retErr)
+	expErr := fmt.Errorf("session.CreateAnonymSession() Create error: %w", retErr)
 
-	smock.EXPECT().Save(ctx, gomock.Any()).Return(nil, retErr)
+	smock.EXPECT().Create(ctx, gomock.Any()).Return(nil, retErr)
 
 	created, err := service.CreateAnonymSession(ctx, cookieConf, sconf)
 
@@ -279,6 +280,41 @@ func TestErrorSavingAnonymSession(t *testing.T) {
 	assert.Equal(t, expErr, err)
 }
 
+func TestCreateSessionIDCollision(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	ctx := context.Background()
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	var sids []string
+	smock.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, s *session.Session) (*session.Session, error) {
+		sids = append(sids, s.ID)
+		if len(sids) == 1 {
+			return nil, session.ErrSessionExists
+		}
+		return s, nil
+	}).Times(2)
+
+	created, err := service.CreateAnonymSession(ctx, session.DefaultCookieConf(), session.DefaultSessionConf())
+	assert.Nil(t, err)
+	assert.Len(t, sids, 2)
+	assert.NotEqual(t, sids[0], sids[1])
+	assert.Equal(t, sids[1], created.ID)
+}
+
+func TestCreateSessionIDCollisionGiveUp(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	ctx := context.Background()
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	smock.EXPECT().Create(ctx, gomock.Any()).Return(nil, session.ErrSessionExists).Times(3)
+
+	created, err := service.CreateUserSession(ctx, "uid", session.DefaultCookieConf(), session.DefaultSessionConf())
+	assert.Nil(t, created)
+	assert.ErrorIs(t, err, session.ErrSessionExists)
+}
+
 func TestSucessfullCreateUserSession(t *testing.T) {
 
 	smock := smocks.NewMockStore(gomock.NewController(t))
@@ -297,7 +333,7 @@ func TestSucessfullCreateUserSession(t *testing.T) {
 
 	resSes := session.Session{}
 
-	smock.EXPECT().Save(ctx, sesFullMatcher{
+	smock.EXPECT().Create(ctx, sesFullMatcher{
 		&session.Session{
 			Active:      true,
 			Anonym:      false,
@@ -328,8 +364,8 @@ func TestErrorSavingUserSession(t *testing.T) {
 	)
 
 	retErr := errors.New("some error")
-	expErr := fmt.Errorf("session.CreateUserSession() Save error: %w", retErr)
-	smock.EXPECT().Save(ctx, gomock.Any()).Return(nil, retErr)
+	expErr := fmt.Errorf("session.CreateUserSession() Create error: %w", retErr)
+	smock.EXPECT().Create(ctx, gomock.Any()).Return(nil, retErr)
 
 	uid := "1234"
 
@@ -357,6 +393,9 @@ func TestLoadSessionErr(t *testing.T) {
 		expErr    error
 	}{
 		{"session not found", session.ErrSessionNotFound, session.ErrSessionNotFound},
+		{"session expired", session.ErrSessionExpired, session.ErrSessionExpired},
+		{"session invalidated", session.ErrSessionInvalidated, session.ErrSessionInvalidated},
+		{"wrapped session state error", fmt.Errorf("store: %w", session.ErrSessionExpired), fmt.Errorf("store: %w", session.ErrSessionExpired)},
 		{"session not found", errors.New("some error"), fmt.Errorf("session.LoadSession() Load error: %w", errors.New("some error"))},
 	}
 
@@ -371,6 +410,200 @@ func TestLoadSessionErr(t *testing.T) {
 	}
 }
 
+func TestIncrementAttribute(t *testing.T) {
+	ctx := context.Background()
+	sid := "1234"
+	resSes := session.Session{}
+
+	tt := []struct {
+		name      string
+		key       string
+		mock      bool
+		returnSes *session.Session
+		returnErr error
+		expErr    error
+	}{
+		{"success", "n", true, &resSes, nil, nil},
+		{"nested key", "a.b", true, &resSes, nil, nil},
+		{"empty key", "", false, nil, nil, fmt.Errorf("session.IncrementAttribute() error: %w", fmt.Errorf("%w: %q", session.ErrInvalidAttributeKey, ""))},
+		{"operator key", "a.$inc", false, nil, nil, fmt.Errorf("session.IncrementAttribute() error: %w", fmt.Errorf("%w: %q", session.ErrInvalidAttributeKey, "a.$inc"))},
+		{"attribute type", "n", true, nil, session.ErrAttributeType, session.ErrAttributeType},
+		{"session expired", "n", true, nil, session.ErrSessionExpired, session.ErrSessionExpired},
+		{"unexpected error", "n", true, nil, errors.New("some err"), fmt.Errorf("session.IncrementAttribute() IncrementAttribute unexpected error: %w", errors.New("some err"))},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock := smocks.NewMockStore(gomock.NewController(t))
+			service := session.NewService(smock, logr.Discard(), "key")
+
+			if tc.mock {
+				smock.EXPECT().IncrementAttribute(ctx, sid, tc.key, int64(3)).Return(tc.returnSes, tc.returnErr)
+			}
+
+			s, err := service.IncrementAttribute(ctx, sid, tc.key, 3)
+			assert.Equal(t, tc.expErr, err)
+			assert.Equal(t, tc.returnSes, s)
+		})
+	}
+}
+
+func TestAppendToAttribute(t *testing.T) {
+	ctx := context.Background()
+	sid := "1234"
+	values := []interface{}{"a", "b"}
+	resSes := session.Session{}
+
+	tt := []struct {
+		name      string
+		key       string
+		values    []interface{}
+		mock      bool
+		returnSes *session.Session
+		returnErr error
+		expErr    error
+	}{
+		{"success", "l", values, true, &resSes, nil, nil},
+		{"no values", "l", nil, false, nil, nil, fmt.Errorf("session.AppendToAttribute() no values to append")},
+		{"invalid key", "$l", values, false, nil, nil, fmt.Errorf("session.AppendToAttribute() error: %w", fmt.Errorf("%w: %q", session.ErrInvalidAttributeKey, "$l"))},
+		{"attribute type", "l", values, true, nil, session.ErrAttributeType, session.ErrAttributeType},
+		{"session not found", "l", values, true, nil, session.ErrSessionNotFound, session.ErrSessionNotFound},
+		{"unexpected error", "l", values, true, nil, errors.New("some err"), fmt.Errorf("session.AppendToAttribute() AppendToAttribute unexpected error: %w", errors.New("some err"))},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock := smocks.NewMockStore(gomock.NewController(t))
+			service := session.NewService(smock, logr.Discard(), "key")
+
+			if tc.mock {
+				smock.EXPECT().AppendToAttribute(ctx, sid, tc.key, tc.values, 10).Return(tc.returnSes, tc.returnErr)
+			}
+
+			s, err := service.AppendToAttribute(ctx, sid, tc.key, tc.values, 10)
+			assert.Equal(t, tc.expErr, err)
+			assert.Equal(t, tc.returnSes, s)
+		})
+	}
+}
+
+func TestUpdateSession(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	ctx := context.Background()
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	s := session.Session{ID: "1234", UID: "uid"}
+	resSes := session.Session{ID: "1234", UID: "uid"}
+
+	smock.EXPECT().Update(ctx, &s).Return(&resSes, nil)
+
+	updated, err := service.UpdateSession(ctx, &s)
+	assert.Nil(t, err)
+	assert.Same(t, &resSes, updated)
+}
+
+func TestUpdateSessionErr(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	ctx := context.Background()
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	s := session.Session{ID: "1234"}
+
+	tt := []struct {
+		name      string
+		returnErr error
+		expErr    error
+	}{
+		{"session not found", session.ErrSessionNotFound, session.ErrSessionNotFound},
+		{"session expired", session.ErrSessionExpired, session.ErrSessionExpired},
+		{"session invalidated", session.ErrSessionInvalidated, session.ErrSessionInvalidated},
+		{"unexpected error", errors.New("some error"), fmt.Errorf("session.UpdateSession() Update unexpected error: %w", errors.New("some error"))},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock.EXPECT().Update(ctx, &s).Return(nil, tc.returnErr)
+
+			updated, err := service.UpdateSession(ctx, &s)
+			assert.Nil(t, updated)
+			assert.Equal(t, tc.expErr, err)
+		})
+	}
+
+	t.Run("nil session", func(t *testing.T) {
+		updated, err := service.UpdateSession(ctx, nil)
+		assert.Nil(t, updated)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestModifySession(t *testing.T) {
+	ctx := context.Background()
+	sid := "1234"
+	retErr := errors.New("some error")
+
+	loaded := func() *session.Session {
+		return &session.Session{ID: sid, Data: map[string]interface{}{"n": 1}, Version: 1}
+	}
+
+	tt := []struct {
+		name      string
+		fnErr     error
+		updErrs   []error
+		expErr    error
+		expCalled int
+	}{
+		{"success", nil, []error{nil}, nil, 1},
+		{"retry on version conflict", nil, []error{session.ErrVersionConflict, nil}, nil, 2},
+		{"give up on version conflict", nil, []error{
+			session.ErrVersionConflict, session.ErrVersionConflict, session.ErrVersionConflict,
+			session.ErrVersionConflict, session.ErrVersionConflict,
+		}, session.ErrVersionConflict, 5},
+		{"session invalidated", nil, []error{session.ErrSessionInvalidated}, session.ErrSessionInvalidated, 1},
+		{"fn error", retErr, nil, retErr, 1},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock := smocks.NewMockStore(gomock.NewController(t))
+			service := session.NewService(smock, logr.Discard(), "key")
+
+			smock.EXPECT().Load(ctx, sid).DoAndReturn(func(context.Context, string) (*session.Session, error) {
+				return loaded(), nil
+			}).Times(tc.expCalled)
+
+			calls := []*gomock.Call{}
+			for _, updErr := range tc.updErrs {
+				updErr := updErr
+				calls = append(calls, smock.EXPECT().Update(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, s *session.Session) (*session.Session, error) {
+					assert.Equal(t, 2, s.Data["n"])
+					if updErr != nil {
+						return nil, updErr
+					}
+					return s, nil
+				}))
+			}
+			gomock.InOrder(calls...)
+
+			called := 0
+			s, err := service.ModifySession(ctx, sid, func(s *session.Session) error {
+				called++
+				s.Data["n"] = s.Data["n"].(int) + 1
+				return tc.fnErr
+			})
+			assert.Equal(t, tc.expCalled, called)
+			assert.Equal(t, tc.expErr, err)
+			if tc.expErr == nil {
+				assert.Equal(t, 2, s.Data["n"])
+			} else {
+				assert.Nil(t, s)
+			}
+		})
+	}
+}
+
 func TestLoadSessionSuccess(t *testing.T) {
 	smock := smocks.NewMockStore(gomock.NewController(t))
 
@@ -394,6 +627,115 @@ func TestLoadSessionSuccess(t *testing.T) {
 	assert.Same(t, &ses, loaded)
 }
 
+func TestLoadSessionServiceClock(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	ctx := context.Background()
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := storetest.NewFakeClock(now)
+
+	service := session.NewService(
+		smock,
+		logr.Discard(),
+		"key",
+		session.WithServiceClock(clock),
+	)
+
+	sid := "1234"
+
+	ses, _ := session.NewSession()
+	ses.ID = sid
+	ses.CreatedAt = now
+	ses.LastAccessedAt = now
+
+	smock.EXPECT().Load(ctx, sid).Return(&ses, nil).Times(2)
+
+	loaded, err := service.LoadSession(ctx, sid)
+	assert.Nil(t, err)
+	assert.Same(t, &ses, loaded)
+
+	// store still reports the session as live in its own time
+	clock.Advance(ses.IdleTimeout + time.Second)
+	loaded, err = service.LoadSession(ctx, sid)
+	assert.Nil(t, loaded)
+	assert.Equal(t, session.ErrSessionExpired, err)
+}
+
+func TestPeekSession(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	ctx := context.Background()
+
+	service := session.NewService(
+		smock,
+		logr.Discard(),
+		"key",
+	)
+
+	sid := "1234"
+
+	ses, _ := session.NewSession()
+	ses.ID = sid
+
+	tt := []struct {
+		name      string
+		returnSes *session.Session
+		returnErr error
+		expErr    error
+	}{
+		{"success", &ses, nil, nil},
+		{"session expired", nil, session.ErrSessionExpired, session.ErrSessionExpired},
+		{"session invalidated", nil, session.ErrSessionInvalidated, session.ErrSessionInvalidated},
+		{"store error", nil, errors.New("some error"), fmt.Errorf("session.PeekSession() Peek error: %w", errors.New("some error"))},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock.EXPECT().Peek(ctx, sid).Return(tc.returnSes, tc.returnErr)
+
+			peeked, err := service.PeekSession(ctx, sid)
+			assert.Equal(t, tc.expErr, err)
+			assert.Equal(t, tc.returnSes, peeked)
+		})
+	}
+}
+
+func TestSessionExists(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	ctx := context.Background()
+
+	service := session.NewService(
+		smock,
+		logr.Discard(),
+		"key",
+	)
+
+	sid := "1234"
+
+	tt := []struct {
+		name      string
+		returnOK  bool
+		returnErr error
+		expOK     bool
+		expErr    error
+	}{
+		{"live session", true, nil, true, nil},
+		{"not live session", false, nil, false, nil},
+		{"store error", false, errors.New("some error"), false, fmt.Errorf("session.SessionExists() Exists error: %w", errors.New("some error"))},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock.EXPECT().Exists(ctx, sid).Return(tc.returnOK, tc.returnErr)
+
+			ok, err := service.SessionExists(ctx, sid)
+			assert.Equal(t, tc.expErr, err)
+			assert.Equal(t, tc.expOK, ok)
+		})
+	}
+}
+
 func TestInvalidateSession(t *testing.T) {
 	smock := smocks.NewMockStore(gomock.NewController(t))
 
@@ -487,6 +829,10 @@ func TestAddAttributesInvalidCases(t *testing.T) {
 		{"odd number of key value paiers, single key", []interface{}{"key"}, "session.AddAttributes() error: expected even count of key and values, got: 1"},
 		{"odd number of key value paiers, multiple keys", []interface{}{"key1", "value1", "key2"}, "session.AddAttributes() error: expected even count of key and values, got: 3"},
 		{"invalid int key", []interface{}{1, "value"}, "session.AddAttributes() error: can't convert key of type: int to string"},
+		{"empty key", []interface{}{"", "value"}, `session.AddAttributes() error: sessionservice: invalid attribute key: ""`},
+		{"empty path segment", []interface{}{"cart..items", "value"}, `session.AddAttributes() error: sessionservice: invalid attribute key: "cart..items"`},
+		{"operator key", []interface{}{"$where", "value"}, `session.AddAttributes() error: sessionservice: invalid attribute key: "$where"`},
+		{"overlapping keys", []interface{}{"cart", "value", "cart.items", "value"}, `session.AddAttributes() error: sessionservice: invalid attribute key: "cart.items" overlaps with "cart"`},
 	}
 
 	for _, tc := range tt {
@@ -499,6 +845,48 @@ func TestAddAttributesInvalidCases(t *testing.T) {
 	}
 }
 
+func TestAddAttributesNestedKey(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	sid := "1111"
+	resSes := session.Session{}
+	expData := map[string]interface{}{
+		"cart.items": []string{"a"},
+		"cart.count": 1,
+	}
+
+	smock.EXPECT().AddAttributes(gomock.Any(), gomock.Eq(sid), gomock.Eq(expData)).Return(&resSes, nil)
+	s, err := service.AddAttributes(context.Background(), sid, "cart.items", []string{"a"}, "cart.count", 1)
+	assert.Nil(t, err)
+	assert.Same(t, &resSes, s)
+}
+
+func TestRemoveAttributesInvalidKeys(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	tt := []struct {
+		name string
+		keys []string
+	}{
+		{"operator key", []string{"cart.$unset"}},
+		{"empty path segment", []string{"cart."}},
+		{"overlapping keys", []string{"cart.items", "cart"}},
+		{"duplicated keys", []string{"cart", "cart"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := service.RemoveAttributes(context.Background(), "1111", tc.keys...)
+			assert.Nil(t, s)
+			assert.ErrorIs(t, err, session.ErrInvalidAttributeKey)
+		})
+	}
+}
+
 func TestAddAttributesSessionNotFound(t *testing.T) {
 	smock := smocks.NewMockStore(gomock.NewController(t))
 
@@ -582,3 +970,279 @@ func TestRemoveAttributesUnexpectedErr(t *testing.T) {
 	assert.Nil(t, s)
 	assert.Equal(t, fmt.Errorf("session.RemoveAttributes() RemoveAttributes unexpected error: %w", retErr), err)
 }
+
+func TestRegenerateSession(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	grace := time.Minute
+	service := session.NewService(smock, logr.Discard(), "key", session.WithRegenerateGrace(grace))
+
+	sid := "1111"
+	resSes := session.Session{}
+
+	smock.
+		EXPECT().
+		Regenerate(gomock.Any(), gomock.Eq(sid), sidMatcher{}, gomock.Eq(grace), gomock.Nil()).
+		Return(&resSes, nil)
+
+	s, err := service.RegenerateSession(context.Background(), sid)
+	assert.Nil(t, err)
+	assert.Same(t, &resSes, s)
+}
+
+func TestRegenerateSessionIDCollision(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	sid := "1111"
+	resSes := session.Session{}
+
+	gomock.InOrder(
+		smock.EXPECT().
+			Regenerate(gomock.Any(), gomock.Eq(sid), sidMatcher{}, gomock.Any(), gomock.Nil()).
+			Return(nil, session.ErrSessionExists),
+		smock.EXPECT().
+			Regenerate(gomock.Any(), gomock.Eq(sid), sidMatcher{}, gomock.Any(), gomock.Nil()).
+			Return(&resSes, nil),
+	)
+
+	s, err := service.RegenerateSession(context.Background(), sid)
+	assert.Nil(t, err)
+	assert.Same(t, &resSes, s)
+}
+
+func TestRegenerateSessionNotFound(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	sid := "1111"
+
+	smock.
+		EXPECT().
+		Regenerate(gomock.Any(), gomock.Eq(sid), sidMatcher{}, gomock.Eq(time.Duration(0)), gomock.Nil()).
+		Return(nil, session.ErrSessionNotFound)
+
+	s, err := service.RegenerateSession(context.Background(), sid)
+	assert.Nil(t, s)
+	assert.Equal(t, session.ErrSessionNotFound, err)
+}
+
+func TestRegenerateSessionUnexpectedErr(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	sid := "1111"
+
+	retErr := errors.New("unexpected error")
+	smock.
+		EXPECT().
+		Regenerate(gomock.Any(), gomock.Eq(sid), sidMatcher{}, gomock.Any(), gomock.Nil()).
+		Return(nil, retErr)
+
+	s, err := service.RegenerateSession(context.Background(), sid)
+	assert.Nil(t, s)
+	assert.Equal(t, fmt.Errorf("session.RegenerateSession() Regenerate unexpected error: %w", retErr), err)
+}
+
+func TestPromoteToUser(t *testing.T) {
+	sconf := session.Conf{IdleTimeout: time.Hour, AbsTimout: 2 * time.Hour}
+
+	tt := []struct {
+		name           string
+		opts           []session.PromoteOption
+		expData        map[string]interface{}
+		expIdleTimeout time.Duration
+		expAbsTimeout  time.Duration
+	}{
+		{
+			"drop attributes by default",
+			nil,
+			map[string]interface{}{},
+			time.Minute, time.Minute,
+		},
+		{
+			"keep attributes",
+			[]session.PromoteOption{session.PromoteKeepAttributes()},
+			map[string]interface{}{"cart": "items", "k": "anon"},
+			time.Minute, time.Minute,
+		},
+		{
+			"keep and override attributes",
+			[]session.PromoteOption{session.PromoteKeepAttributes(), session.PromoteAttributes("k", "user", "role", "admin")},
+			map[string]interface{}{"cart": "items", "k": "user", "role": "admin"},
+			time.Minute, time.Minute,
+		},
+		{
+			"new attributes only",
+			[]session.PromoteOption{session.PromoteAttributes("role", "admin")},
+			map[string]interface{}{"role": "admin"},
+			time.Minute, time.Minute,
+		},
+		{
+			"reset timeouts",
+			[]session.PromoteOption{session.PromoteSessionConf(sconf)},
+			map[string]interface{}{},
+			sconf.IdleTimeout, sconf.AbsTimout,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock := smocks.NewMockStore(gomock.NewController(t))
+			service := session.NewService(smock, logr.Discard(), "key")
+
+			sid := "1111"
+			uid := "2222"
+
+			smock.
+				EXPECT().
+				Regenerate(gomock.Any(), gomock.Eq(sid), sidMatcher{}, gomock.Any(), gomock.Not(gomock.Nil())).
+				DoAndReturn(func(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*session.Session)) (*session.Session, error) {
+					s := session.Session{
+						ID:          sid,
+						Data:        map[string]interface{}{"cart": "items", "k": "anon"},
+						Anonym:      true,
+						Active:      true,
+						IdleTimeout: time.Minute,
+						AbsTimeout:  time.Minute,
+					}
+					modify(&s)
+					s.ID = newSID
+					return &s, nil
+				})
+
+			s, err := service.PromoteToUser(context.Background(), sid, uid, tc.opts...)
+			assert.Nil(t, err)
+			assert.Equal(t, uid, s.UID)
+			assert.False(t, s.Anonym)
+			assert.NotEqual(t, sid, s.ID)
+			assert.Equal(t, tc.expData, s.Data)
+			assert.Equal(t, tc.expIdleTimeout, s.IdleTimeout)
+			assert.Equal(t, tc.expAbsTimeout, s.AbsTimeout)
+		})
+	}
+}
+
+func TestPromoteToUserBadAttributes(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	s, err := service.PromoteToUser(context.Background(), "1111", "2222", session.PromoteAttributes("key"))
+	assert.Nil(t, s)
+	assert.Equal(t, "session.PromoteToUser() error: expected even count of key and values, got: 1", err.Error())
+}
+
+func TestPromoteToUserSessionNotFound(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	sid := "1111"
+
+	smock.
+		EXPECT().
+		Regenerate(gomock.Any(), gomock.Eq(sid), sidMatcher{}, gomock.Any(), gomock.Any()).
+		Return(nil, session.ErrSessionNotFound)
+
+	s, err := service.PromoteToUser(context.Background(), sid, "2222")
+	assert.Nil(t, s)
+	assert.Equal(t, session.ErrSessionNotFound, err)
+}
+
+func TestListUserSessions(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	uid := "2222"
+	resSessions := []*session.Session{{ID: "1"}, {ID: "2"}}
+
+	smock.EXPECT().ListUserSessions(gomock.Any(), gomock.Eq(uid)).Return(resSessions, nil)
+
+	sessions, err := service.ListUserSessions(context.Background(), uid)
+	assert.Nil(t, err)
+	assert.Equal(t, resSessions, sessions)
+}
+
+func TestListUserSessionsErrors(t *testing.T) {
+	retErr := errors.New("unexpected error")
+
+	tt := []struct {
+		name     string
+		uid      string
+		storeErr error
+		expErr   error
+	}{
+		{"empty uid", "", nil, fmt.Errorf("session.ListUserSessions() empty uid")},
+		{"unexpected error", "2222", retErr, fmt.Errorf("session.ListUserSessions() ListUserSessions unexpected error: %w", retErr)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock := smocks.NewMockStore(gomock.NewController(t))
+			service := session.NewService(smock, logr.Discard(), "key")
+
+			if tc.storeErr != nil {
+				smock.EXPECT().ListUserSessions(gomock.Any(), gomock.Eq(tc.uid)).Return(nil, tc.storeErr)
+			}
+
+			sessions, err := service.ListUserSessions(context.Background(), tc.uid)
+			assert.Nil(t, sessions)
+			assert.Equal(t, tc.expErr, err)
+		})
+	}
+}
+
+func TestInvalidateUserSessions(t *testing.T) {
+	smock := smocks.NewMockStore(gomock.NewController(t))
+	service := session.NewService(smock, logr.Discard(), "key")
+
+	uid := "2222"
+	sid := "1111"
+
+	smock.EXPECT().InvalidateUserSessions(gomock.Any(), gomock.Eq(uid), gomock.Eq(sid)).Return(nil)
+
+	err := service.InvalidateUserSessions(context.Background(), uid, sid)
+	assert.Nil(t, err)
+}
+
+func TestInvalidateUserSessionsErrors(t *testing.T) {
+	retErr := errors.New("unexpected error")
+
+	tt := []struct {
+		name     string
+		uid      string
+		storeErr error
+		expErr   error
+	}{
+		{"empty uid", "", nil, fmt.Errorf("session.InvalidateUserSessions() empty uid")},
+		{"unexpected error", "2222", retErr, fmt.Errorf("session.InvalidateUserSessions() InvalidateUserSessions unexpected error: %w", retErr)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smock := smocks.NewMockStore(gomock.NewController(t))
+			service := session.NewService(smock, logr.Discard(), "key")
+
+			if tc.storeErr != nil {
+				smock.EXPECT().InvalidateUserSessions(gomock.Any(), gomock.Eq(tc.uid)).Return(tc.storeErr)
+			}
+
+			err := service.InvalidateUserSessions(context.Background(), tc.uid)
+			assert.Equal(t, tc.expErr, err)
+		})
+	}
+}
+
+// sidMatcher matches any valid session id
+type sidMatcher struct{}
+
+func (sidMatcher) Matches(x interface{}) bool {
+	sid, ok := x.(string)
+	return ok && session.ValidateSessionID(sid) == nil
+}
+
+func (sidMatcher) String() string {
+	return "is valid session id"
+}
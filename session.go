@@ -1,11 +1,7 @@
 package session
 
 import (
-	"crypto/rand"
-	"encoding/base32"
 	"fmt"
-	"io"
-	"reflect"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -23,6 +19,9 @@ import (
 // UID is supposed to store user identity who session belongs to.
 //
 // Anonym is supposed to use during authentication process.
+//
+// Version is incremented by Store on every change of the session,
+// Store.Update uses it to detect concurrent modifications.
 type Session struct {
 	ID   string
 	Data map[string]interface{}
@@ -38,6 +37,8 @@ type Session struct {
 
 	LastAccessedAt time.Time
 	CreatedAt      time.Time
+
+	Version int64
 }
 
 type SameSite int
@@ -68,11 +69,6 @@ type Conf struct {
 // CtxKey type alias for session data attributes keys
 type CtxKey string
 
-var (
-	base32enc = base32.StdEncoding.WithPadding(base32.NoPadding)
-	keyLen    = 32
-)
-
 func DefaultCookieConf() CookieConf {
 	return CookieConf{
 		Secure:   true,
@@ -97,11 +93,15 @@ func DefaultSessionConf() Conf {
 // Active = true
 // Opts: Secure, HTTPOnly, Strict
 func NewSession() (Session, error) {
-	id, err := generateSessionID()
+	id, err := defaultIDGenerator.Generate()
 	if err != nil {
 		return Session{}, err
 	}
-	s := Session{
+	return newSessionWithID(id), nil
+}
+
+func newSessionWithID(id string) Session {
+	return Session{
 		ID:          id,
 		Data:        make(map[string]interface{}),
 		Opts:        DefaultCookieConf(),
@@ -110,7 +110,6 @@ func NewSession() (Session, error) {
 		IdleTimeout: DefaultSessionConf().IdleTimeout,
 		AbsTimeout:  DefaultSessionConf().AbsTimout,
 	}
-	return s, nil
 }
 
 // WithUserID add user identity to the session
@@ -130,14 +129,18 @@ func (s *Session) WithSessionConf(sc Conf) {
 	s.AbsTimeout = sc.AbsTimout
 }
 
-// IsExpired check if session is expired
+// IsExpired check if session is expired at system time,
+// it's a shortcut for IsExpiredAt(time.Now()), code with injected Clock should use IsExpiredAt
 func (s *Session) IsExpired() bool {
+	return s.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt check if session is expired at the moment now
+func (s *Session) IsExpiredAt(now time.Time) bool {
 	if !s.Active {
 		return true
 	}
 
-	now := time.Now()
-
 	if s.LastAccessedAt.Add(s.IdleTimeout).Before(now) {
 		return true
 	}
@@ -149,313 +152,168 @@ func (s *Session) IsExpired() bool {
 	return false
 }
 
-// ValidateSessionID validate session id format
-func ValidateSessionID(sid string) error {
-	if len(sid) != base32enc.EncodedLen(keyLen) {
-		return fmt.Errorf("error validating session: wrong session id length")
+// CheckExpired return ErrSessionInvalidated if session isn't active,
+// ErrSessionExpired if session is expired by idle or absolute timeout
+// and nil otherwise. It checks expiry at system time,
+// code with injected Clock should use CheckExpiredAt
+func (s *Session) CheckExpired() error {
+	return s.CheckExpiredAt(time.Now())
+}
+
+// CheckExpiredAt is the same as CheckExpired at the moment now
+func (s *Session) CheckExpiredAt(now time.Time) error {
+	if !s.Active {
+		return ErrSessionInvalidated
 	}
-	_, err := base32enc.DecodeString(sid)
-	if err != nil {
-		return fmt.Errorf("error validating session: %w", err)
+	if s.IsExpiredAt(now) {
+		return ErrSessionExpired
 	}
 	return nil
 }
 
+// ValidateSessionID validate format of session id generated by default IDGenerator,
+// session id signed by Signer (<sid>.<mac>) is accepted as well.
+// If signers are passed session id should be signed and signature is verified by them.
+// Without signers only the format is checked: signature is stripped but NOT verified,
+// so it's up to Service created WithSigner to reject forged ids
+func ValidateSessionID(sid string, signers ...*Signer) error {
+	if len(signers) > 0 {
+		var err error
+		for _, sg := range signers {
+			var unsigned string
+			unsigned, err = sg.Verify(sid)
+			if err == nil {
+				sid = unsigned
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error validating session: %w", err)
+		}
+	} else if unsigned, _, ok := splitSignature(sid); ok {
+		sid = unsigned
+	}
+
+	return defaultIDGenerator.Validate(sid)
+}
+
 func (s *Session) WithAttributes(attrs map[string]interface{}) {
 	for k, v := range attrs {
 		s.AddAttribute(k, v)
 	}
 }
 
-// AddAttribute add a new attribute to the session
+// AddAttribute add a new attribute to the session,
+// k may be nested attribute path, e.g. "cart.items" (see AttributePathSeparator),
+// missing maps on the path are created and other values on the path are replaced by maps
 func (s *Session) AddAttribute(k string, v interface{}) {
-	s.Data[k] = v
+	if s.Data == nil {
+		s.Data = make(map[string]interface{})
+	}
+	setPath(s.Data, SplitAttributePath(k), v)
+}
+
+// RemoveAttribute remove attribute from the session,
+// k may be nested attribute path
+func (s *Session) RemoveAttribute(k string) {
+	removePath(s.Data, SplitAttributePath(k))
 }
 
-// GetAttribute return a value from the session
+// GetAttribute return a value from the session,
+// k may be nested attribute path, only map[string]interface{} values are walked through.
 // It return nill and false if attribute doesn't exists
 func (s *Session) GetAttribute(k string) (interface{}, bool) {
-	v, ok := s.Data[k]
-	return v, ok
+	return getPath(s.Data, SplitAttributePath(k))
 }
 
-// GetString return a value as string from the session by key
-// If value isn't a string, it won't be converted
+// GetString return a value as string from the session by key,
+// it's converted the same way as by Get[string]
 func (s *Session) GetString(k string) (string, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return "", ok
-	}
-
-	switch cv := v.(type) {
-	case string:
-		return cv, true
-	default:
-		return "", false
-	}
+	return Get[string](s, k)
 }
 
-// GetInt return a value as int from the session by key
-// if Value one of (byte/int8, int16, int32, int64, int)
-// return (0, false) otherwise
+// GetInt return a value as int from the session by key,
+// it's converted the same way as by Get[int]
 func (s *Session) GetInt(k string) (int, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return 0, ok
-	}
-
-	switch cv := v.(type) {
-	case byte:
-		return int(cv), true
-	case int8:
-		return int(cv), true
-	case int16:
-		return int(cv), true
-	case int32:
-		return int(cv), true
-	case int64:
-		return int(cv), true
-	case int:
-		return cv, true
-	default:
-		return 0, false
-	}
+	return Get[int](s, k)
 }
 
-// GetInt64 return a value as int64 from the session by key
-// if Value one of (byte/int8, int16, int32, int64, int)
-// return (0, false) otherwise
+// GetInt64 return a value as int64 from the session by key,
+// it's converted the same way as by Get[int64]
 func (s *Session) GetInt64(k string) (int64, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return 0, ok
-	}
-
-	switch cv := v.(type) {
-	case byte:
-		return int64(cv), true
-	case int8:
-		return int64(cv), true
-	case int16:
-		return int64(cv), true
-	case int32:
-		return int64(cv), true
-	case int:
-		return int64(cv), true
-	case int64:
-		return cv, true
-	default:
-		return 0, false
-	}
+	return Get[int64](s, k)
 }
 
-// GetFloat32 return a value as float32 from the session by key
-// if Value is float32
-// return (0.0, false) otherwise
+// GetFloat32 return a value as float32 from the session by key,
+// it's converted the same way as by Get[float32]
 func (s *Session) GetFloat32(k string) (float32, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return 0, ok
-	}
-
-	switch cv := v.(type) {
-	case float32:
-		return cv, true
-	default:
-		return 0.0, false
-	}
+	return Get[float32](s, k)
 }
 
-// GetFloat64 return a value as float64 from the session by key
-// if Value is one of (float32, float64)
-// return (0.0, false) otherwise
+// GetFloat64 return a value as float64 from the session by key,
+// it's converted the same way as by Get[float64]
 func (s *Session) GetFloat64(k string) (float64, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return 0, ok
-	}
-
-	switch cv := v.(type) {
-	case float32:
-		return float64(cv), true
-	case float64:
-		return cv, true
-	default:
-		return 0.0, false
-	}
+	return Get[float64](s, k)
 }
 
-// GetBool return a value as bool from the session by key
-// if Value is bool
-// return (false, false) otherwise
-func (s *Session) GetBool(k string) (v, ok bool) {
-	vl, ok := s.GetAttribute(k)
-	if !ok {
-		return false, ok
-	}
-
-	switch cv := vl.(type) {
-	case bool:
-		return cv, true
-	default:
-		return false, false
-	}
+// GetBool return a value as bool from the session by key,
+// it's converted the same way as by Get[bool]
+func (s *Session) GetBool(k string) (bool, bool) {
+	return Get[bool](s, k)
 }
 
-// GetTime return a value as time.Time from the session by key
-// if Value is time.Time
-// return (time.Time{}, false) otherwise
+// GetTime return a value as time.Time from the session by key,
+// it's converted the same way as by Get[time.Time]
 func (s *Session) GetTime(k string) (time.Time, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return time.Time{}, ok
-	}
-
-	switch cv := v.(type) {
-	case time.Time:
-		return cv, true
-	default:
-		return time.Time{}, false
-	}
+	return Get[time.Time](s, k)
 }
 
-// GetSlice return a value as []interface{} from the session by key
-// if Value is slice
-// return (nil, false) otherwise
+// GetSlice return a value as []interface{} from the session by key,
+// it's converted the same way as by Get[[]interface{}]
 func (s *Session) GetSlice(k string) ([]interface{}, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	if reflect.TypeOf(v).Kind() != reflect.Slice {
-		return nil, false
-	}
-
-	cv, _ := v.([]interface{})
-	return cv, true
+	return Get[[]interface{}](s, k)
 }
 
-// GetInt32Slice return a value as []int32 from the session by key
-// if Value is []int32
-// (it won't be converted to []int32, if slice has type of another int)
-// return (nil, false) otherwise
+// GetInt32Slice return a value as []int32 from the session by key,
+// it's converted the same way as by Get[[]int32]
 func (s *Session) GetInt32Slice(k string) ([]int32, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	switch cv := v.(type) {
-	case []int32:
-		return cv, true
-	default:
-		return nil, false
-	}
+	return Get[[]int32](s, k)
 }
 
-// GetInt64Slice return a value as []int64 from the session by key
-// if Value is []int64
-// (it won't be converted to []int64, if slice has type of another int)
-// return (nil, false) otherwise
+// GetInt64Slice return a value as []int64 from the session by key,
+// it's converted the same way as by Get[[]int64]
 func (s *Session) GetInt64Slice(k string) ([]int64, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	switch cv := v.(type) {
-	case []int64:
-		return cv, true
-	default:
-		return nil, false
-	}
+	return Get[[]int64](s, k)
 }
 
-// GetFloat32Slice return a value as []float32 from the session by key
-// if Value is []float32
-// return (nil, false) otherwise
+// GetFloat32Slice return a value as []float32 from the session by key,
+// it's converted the same way as by Get[[]float32]
 func (s *Session) GetFloat32Slice(k string) ([]float32, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	switch cv := v.(type) {
-	case []float32:
-		return cv, true
-	default:
-		return nil, false
-	}
+	return Get[[]float32](s, k)
 }
 
-// GetFloat64Slice return a value as []float64 from the session by key
-// if Value is []float64
-// (it won't be converted to []float64, if slice has type float32)
-// return (nil, false) otherwise
+// GetFloat64Slice return a value as []float64 from the session by key,
+// it's converted the same way as by Get[[]float64]
 func (s *Session) GetFloat64Slice(k string) ([]float64, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	switch cv := v.(type) {
-	case []float64:
-		return cv, true
-	default:
-		return nil, false
-	}
+	return Get[[]float64](s, k)
 }
 
-// GetStringSlice return a value as []string from the session by key
-// if Value is []string
-// return (nil, false) otherwise
+// GetStringSlice return a value as []string from the session by key,
+// it's converted the same way as by Get[[]string]
 func (s *Session) GetStringSlice(k string) ([]string, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	switch cv := v.(type) {
-	case []string:
-		return cv, true
-	default:
-		return nil, false
-	}
+	return Get[[]string](s, k)
 }
 
-// GetBoolSlice return a value as []bool from the session by key
-// if Value is []bool
-// return (nil, false) otherwise
+// GetBoolSlice return a value as []bool from the session by key,
+// it's converted the same way as by Get[[]bool]
 func (s *Session) GetBoolSlice(k string) ([]bool, bool) {
-	v, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	switch cv := v.(type) {
-	case []bool:
-		return cv, true
-	default:
-		return nil, false
-	}
+	return Get[[]bool](s, k)
 }
 
-// GetTimeSlice return a value as []time.Time from the session by key
-// if Value is []time.Time
-// return (nil, false) otherwise
+// GetTimeSlice return a value as []time.Time from the session by key,
+// it's converted the same way as by Get[[]time.Time]
 func (s *Session) GetTimeSlice(k string) ([]time.Time, bool) {
-	mapV, ok := s.GetAttribute(k)
-	if !ok {
-		return nil, ok
-	}
-
-	switch cv := mapV.(type) {
-	case []time.Time:
-		return cv, true
-	default:
-		return nil, false
-	}
+	return Get[[]time.Time](s, k)
 }
 
 // GetStruct convert map or struct from the session by key
@@ -485,12 +343,3 @@ func (s *Session) GetStructWithDecoder(k string, decoder *mapstructure.Decoder)
 	err := decoder.Decode(v)
 	return err == nil
 }
-
-func generateSessionID() (string, error) {
-	id := make([]byte, keyLen)
-	_, err := io.ReadFull(rand.Reader, id)
-	if err != nil {
-		return "", fmt.Errorf("error generating session id: %w", err)
-	}
-	return base32enc.EncodeToString(id), nil
-}
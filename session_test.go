@@ -64,6 +64,58 @@ func TestSessionIsExpired(t *testing.T) {
 	}
 }
 
+func TestSessionIsExpiredAt(t *testing.T) {
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := session.Session{
+		Active:         true,
+		IdleTimeout:    time.Hour,
+		AbsTimeout:     3 * time.Hour,
+		CreatedAt:      created,
+		LastAccessedAt: created.Add(90 * time.Minute),
+	}
+
+	tt := []struct {
+		name       string
+		now        time.Time
+		expExpired bool
+	}{
+		{"at creation", created, false},
+		{"idle timeout isn't passed", created.Add(150 * time.Minute), false},
+		{"idle timeout is passed", created.Add(150*time.Minute + time.Second), true},
+		{"absolute timeout is passed", created.Add(3*time.Hour + time.Second), true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expExpired, s.IsExpiredAt(tc.now))
+		})
+	}
+
+	s.LastAccessedAt = created.Add(150 * time.Minute)
+	assert.Nil(t, s.CheckExpiredAt(created.Add(3*time.Hour)))
+	assert.Equal(t, session.ErrSessionExpired, s.CheckExpiredAt(created.Add(3*time.Hour+time.Second)))
+}
+
+func TestSessionCheckExpired(t *testing.T) {
+	tt := []struct {
+		name   string
+		expErr error
+		s      session.Session
+	}{
+		{"inactive session", session.ErrSessionInvalidated, session.Session{Active: false, IdleTimeout: 60 * time.Minute, AbsTimeout: 60 * time.Minute, CreatedAt: time.Now(), LastAccessedAt: time.Now()}},
+		{"inactive expired session", session.ErrSessionInvalidated, session.Session{Active: false, IdleTimeout: 0, AbsTimeout: 0, CreatedAt: time.Now(), LastAccessedAt: time.Now()}},
+		{"expired idle", session.ErrSessionExpired, session.Session{Active: true, IdleTimeout: 0, AbsTimeout: 60 * time.Minute, CreatedAt: time.Now(), LastAccessedAt: time.Now()}},
+		{"expired abs", session.ErrSessionExpired, session.Session{Active: true, IdleTimeout: 60 * time.Minute, AbsTimeout: 0, CreatedAt: time.Now(), LastAccessedAt: time.Now()}},
+		{"live session", nil, session.Session{Active: true, IdleTimeout: 60 * time.Minute, AbsTimeout: 60 * time.Minute, CreatedAt: time.Now(), LastAccessedAt: time.Now()}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expErr, tc.s.CheckExpired())
+		})
+	}
+}
+
 func TestCastIntAttributes(t *testing.T) {
 	tt := []struct {
 		name          string
@@ -201,12 +253,12 @@ func TestCastFloat32Attributes(t *testing.T) {
 		expectedValue interface{}
 		expectedType  reflect.Type
 	}{
-		{"byte as float32", "bool", byte(8), false, 0.0, reflect.TypeOf(float32(1.1))},
-		{"int8 as float32", "int8", int8(8), false, 0.0, reflect.TypeOf(float32(1.1))},
-		{"int16 as float32", "int16", int16(8), false, 0.0, reflect.TypeOf(float32(1.1))},
-		{"int32 as float32", "int32", int32(8), false, 0.0, reflect.TypeOf(float32(1.1))},
-		{"int as float32", "int", int(8), false, 0.0, reflect.TypeOf(float32(1.1))},
-		{"int64 as float32", "int64", int64(8), false, 0.0, reflect.TypeOf(float32(1.1))},
+		{"byte as float32", "bool", byte(8), true, 8.0, reflect.TypeOf(float32(1.1))},
+		{"int8 as float32", "int8", int8(8), true, 8.0, reflect.TypeOf(float32(1.1))},
+		{"int16 as float32", "int16", int16(8), true, 8.0, reflect.TypeOf(float32(1.1))},
+		{"int32 as float32", "int32", int32(8), true, 8.0, reflect.TypeOf(float32(1.1))},
+		{"int as float32", "int", int(8), true, 8.0, reflect.TypeOf(float32(1.1))},
+		{"int64 as float32", "int64", int64(8), true, 8.0, reflect.TypeOf(float32(1.1))},
 		{"string as float32", "string", "va", false, 0.0, reflect.TypeOf(float32(1.1))},
 		{"bool as float32", "bool", true, false, 0.0, reflect.TypeOf(float32(1.1))},
 		{"time.Time as float32", "time.Time", time.Now(), false, 0.0, reflect.TypeOf(float32(1.1))},
@@ -235,12 +287,12 @@ func TestCastFloat64Attributes(t *testing.T) {
 		expectedValue interface{}
 		expectedType  reflect.Type
 	}{
-		{"byte as float64", "bool", byte(8), false, 0.0, reflect.TypeOf(float64(1.1))},
-		{"int8 as float64", "int8", int8(8), false, 0.0, reflect.TypeOf(float64(1.1))},
-		{"int16 as float64", "int16", int16(8), false, 0.0, reflect.TypeOf(float64(1.1))},
-		{"int32 as float64", "int32", int32(8), false, 0.0, reflect.TypeOf(float64(1.1))},
-		{"int as float64", "int", int(8), false, 0.0, reflect.TypeOf(float64(1.1))},
-		{"int64 as float64", "int64", int64(8), false, 0.0, reflect.TypeOf(float64(1.1))},
+		{"byte as float64", "bool", byte(8), true, 8.0, reflect.TypeOf(float64(1.1))},
+		{"int8 as float64", "int8", int8(8), true, 8.0, reflect.TypeOf(float64(1.1))},
+		{"int16 as float64", "int16", int16(8), true, 8.0, reflect.TypeOf(float64(1.1))},
+		{"int32 as float64", "int32", int32(8), true, 8.0, reflect.TypeOf(float64(1.1))},
+		{"int as float64", "int", int(8), true, 8.0, reflect.TypeOf(float64(1.1))},
+		{"int64 as float64", "int64", int64(8), true, 8.0, reflect.TypeOf(float64(1.1))},
 		{"string as float64", "string", "va", false, 0.0, reflect.TypeOf(float64(1.1))},
 		{"bool as float64", "bool", true, false, 0.0, reflect.TypeOf(float64(1.1))},
 		{"time.Time as float64", "time.Time", time.Now(), false, 0.0, reflect.TypeOf(float64(1.1))},
@@ -351,11 +403,11 @@ func TestCastSliceIntAttributes(t *testing.T) {
 		{"time.Time as []int32{}", "time.Time", time.Now(), false, nil, reflect.TypeOf([]int32{})},
 		{"float32 as []int32{}", "float32", float32(1.1), false, nil, reflect.TypeOf([]int32{})},
 		{"float64 as []int32{}", "float64", float64(1.1), false, nil, reflect.TypeOf([]int32{})},
-		{"[]interface{} as []int32{}", "[]interface{}", []interface{}{1, 2, 3}, false, nil, reflect.TypeOf([]int32{})},
-		{"[]int as []int{}", "[]int32", []int{1, 2, 3}, false, nil, reflect.TypeOf([]int32{})},
+		{"[]interface{} as []int32{}", "[]interface{}", []interface{}{1, 2, 3}, true, []int32{1, 2, 3}, reflect.TypeOf([]int32{})},
+		{"[]int as []int{}", "[]int32", []int{1, 2, 3}, true, []int32{1, 2, 3}, reflect.TypeOf([]int32{})},
 		{"[]int(int64 actually) as []int", "[]int32", []int{10000000000, 10000000001, 10000000002}, false, nil, reflect.TypeOf([]int32{})},
 		{"[]32int as []int{}", "[]int32", []int32{1, 2, 3}, true, []int32{1, 2, 3}, reflect.TypeOf([]int32{})},
-		{"[]64int as []int{}", "[]int32", []int64{1, 2, 3}, false, nil, reflect.TypeOf([]int32{})},
+		{"[]64int as []int{}", "[]int32", []int64{1, 2, 3}, true, []int32{1, 2, 3}, reflect.TypeOf([]int32{})},
 		{"[]string as []int32{}", "[]string", []string{"1", "2", "3"}, false, nil, reflect.TypeOf([]int32{})},
 	}
 
@@ -391,10 +443,10 @@ func TestCastSliceInt64Attributes(t *testing.T) {
 		{"time.Time as []int64{}", "time.Time", time.Now(), false, nil, reflect.TypeOf([]int64{})},
 		{"float32 as []int64{}", "float32", float32(1.1), false, nil, reflect.TypeOf([]int64{})},
 		{"float64 as []int64{}", "float64", float64(1.1), false, nil, reflect.TypeOf([]int64{})},
-		{"[]interface{} as []int64{}", "[]interface{}", []interface{}{1, 2, 3}, false, nil, reflect.TypeOf([]int64{})},
-		{"[]int as []int64{}", "[]int64", []int{1, 2, 3}, false, nil, reflect.TypeOf([]int64{})},
-		{"[]int(int64 actually) as []int64", "[]int64", []int{10000000000, 10000000001, 10000000002}, false, nil, reflect.TypeOf([]int64{})},
-		{"[]int32 as []int64{}", "[]int64", []int32{1, 2, 3}, false, nil, reflect.TypeOf([]int64{})},
+		{"[]interface{} as []int64{}", "[]interface{}", []interface{}{1, 2, 3}, true, []int64{1, 2, 3}, reflect.TypeOf([]int64{})},
+		{"[]int as []int64{}", "[]int64", []int{1, 2, 3}, true, []int64{1, 2, 3}, reflect.TypeOf([]int64{})},
+		{"[]int(int64 actually) as []int64", "[]int64", []int{10000000000, 10000000001, 10000000002}, true, []int64{10000000000, 10000000001, 10000000002}, reflect.TypeOf([]int64{})},
+		{"[]int32 as []int64{}", "[]int64", []int32{1, 2, 3}, true, []int64{1, 2, 3}, reflect.TypeOf([]int64{})},
 		{"[]int64 as []int64{}", "[]int64", []int64{1, 2, 3}, true, []int64{1, 2, 3}, reflect.TypeOf([]int64{})},
 		{"[]string as []int64{}", "[]string", []string{"1", "2", "3"}, false, nil, reflect.TypeOf([]int64{})},
 	}
@@ -432,7 +484,7 @@ func TestCastSliceFloat32Attributes(t *testing.T) {
 		{"float32 as []float32{}", "float32", float32(1.1), false, nil, reflect.TypeOf([]float32{})},
 		{"float64 as []float32{}", "float64", float64(1.1), false, nil, reflect.TypeOf([]float32{})},
 		{"[]float32 as []float32{}", "[]float32", []float32{1, 2, 3}, true, []float32{1, 2, 3}, reflect.TypeOf([]float32{})},
-		{"[]float64 as []float32{}", "[]int64", []float64{1, 2, 3}, false, nil, reflect.TypeOf([]float32{})},
+		{"[]float64 as []float32{}", "[]int64", []float64{1, 2, 3}, true, []float32{1, 2, 3}, reflect.TypeOf([]float32{})},
 		{"[]string as []float32{}", "[]string", []string{"1", "2", "3"}, false, nil, reflect.TypeOf([]float32{})},
 	}
 
@@ -468,7 +520,7 @@ func TestCastSliceFloat64Attributes(t *testing.T) {
 		{"time.Time as []float64{}", "time.Time", time.Now(), false, nil, reflect.TypeOf([]float64{})},
 		{"float32 as []float64{}", "float32", float32(1.1), false, nil, reflect.TypeOf([]float64{})},
 		{"float64 as []float64{}", "float64", float64(1.1), false, nil, reflect.TypeOf([]float64{})},
-		{"[]float32 as []float64{}", "[]float32", []float32{1, 2, 3}, false, nil, reflect.TypeOf([]float64{})},
+		{"[]float32 as []float64{}", "[]float32", []float32{1, 2, 3}, true, []float64{1, 2, 3}, reflect.TypeOf([]float64{})},
 		{"[]float64 as []float64{}", "[]int64", []float64{1, 2, 3}, true, []float64{1, 2, 3}, reflect.TypeOf([]float64{})},
 		{"[]string as []float64{}", "[]string", []string{"1", "2", "3"}, false, nil, reflect.TypeOf([]float64{})},
 	}
@@ -0,0 +1,220 @@
+package sidhash
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/go-logr/logr"
+)
+
+var hashEnc = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// hashPrefix mark ids kept by the store, so they can't be mistaken for plain ids of legacy sessions,
+// "~" isn't used by base32 and base64url encodings of generated ids
+const hashPrefix = "~h"
+
+type hashStore struct {
+	session.Store
+
+	Logger      logr.Logger
+	CtxReqIDKey interface{}
+	Key         []byte
+	Migrate     bool
+}
+
+// Option configures store created by NewStore
+type Option func(*hashStore)
+
+// WithKey make the store keep HMAC-SHA256 of session ids keyed with key instead of plain SHA-256,
+// so ids can't be checked against hashes without the key
+func WithKey(key []byte) Option {
+	return func(hs *hashStore) {
+		hs.Key = key
+	}
+}
+
+// WithMigration make the store look up sessions by plain id when there is no session with hashed id,
+// such sessions are moved to hashed id with session.Store.Regenerate on first access.
+// Ids kept by the store are never looked up as plain ids, so they can't be used to take over sessions.
+// It's supposed to be enabled until sessions stored before hashing expire,
+// since every lookup of unknown id costs an extra call to the underlying store
+func WithMigration() Option {
+	return func(hs *hashStore) {
+		hs.Migrate = true
+	}
+}
+
+/*
+NewStore Create session.Store which keeps sessions in s by hash of their ids,
+so ids read from the underlying store (e.g. from database backup) can't be used to hijack sessions.
+
+Ids are hashed with SHA-256 (see WithKey), base32 encoded and prefixed with "~h".
+Sessions returned to callers carry plain ids, except ListUserSessions
+which return hashed ids, they can be passed to Invalidate and InvalidateUserSessions but can't be loaded.
+The store should wrap stores which bind Data to session id, like encrypt store, so they see ids kept in s.
+
+reqIDKey is key to extract request id from the context
+*/
+func NewStore(s session.Store, l logr.Logger, reqIDKey interface{}, opts ...Option) session.Store {
+	hs := &hashStore{
+		Store:       s,
+		Logger:      l,
+		CtxReqIDKey: reqIDKey,
+	}
+	for _, o := range opts {
+		o(hs)
+	}
+	return hs
+}
+
+func (hs *hashStore) Save(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ns := *s
+	ns.ID = hs.hash(s.ID)
+	svdS, err := hs.Store.Save(ctx, &ns)
+	return withID(svdS, err, s.ID)
+}
+
+func (hs *hashStore) Create(ctx context.Context, s *session.Session) (*session.Session, error) {
+	ns := *s
+	ns.ID = hs.hash(s.ID)
+	svdS, err := hs.Store.Create(ctx, &ns)
+	return withID(svdS, err, s.ID)
+}
+
+func (hs *hashStore) AddAttributes(ctx context.Context, sid string, data map[string]interface{}) (*session.Session, error) {
+	return hs.do(ctx, "AddAttributes", sid, func(hsid string) (*session.Session, error) {
+		return hs.Store.AddAttributes(ctx, hsid, data)
+	})
+}
+
+func (hs *hashStore) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*session.Session, error) {
+	return hs.do(ctx, "RemoveAttributes", sid, func(hsid string) (*session.Session, error) {
+		return hs.Store.RemoveAttributes(ctx, hsid, keys...)
+	})
+}
+
+func (hs *hashStore) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*session.Session, error) {
+	return hs.do(ctx, "IncrementAttribute", sid, func(hsid string) (*session.Session, error) {
+		return hs.Store.IncrementAttribute(ctx, hsid, key, delta)
+	})
+}
+
+func (hs *hashStore) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*session.Session, error) {
+	return hs.do(ctx, "AppendToAttribute", sid, func(hsid string) (*session.Session, error) {
+		return hs.Store.AppendToAttribute(ctx, hsid, key, values, maxLen)
+	})
+}
+
+func (hs *hashStore) Load(ctx context.Context, sid string) (*session.Session, error) {
+	return hs.do(ctx, "Load", sid, func(hsid string) (*session.Session, error) {
+		return hs.Store.Load(ctx, hsid)
+	})
+}
+
+// Peek doesn't migrate legacy session since it mustn't write,
+// the session is looked up by plain id instead
+func (hs *hashStore) Peek(ctx context.Context, sid string) (*session.Session, error) {
+	s, err := hs.Store.Peek(ctx, hs.hash(sid))
+	if errors.Is(err, session.ErrSessionNotFound) && hs.legacy(sid) {
+		s, err = hs.Store.Peek(ctx, sid)
+	}
+	return withID(s, err, sid)
+}
+
+func (hs *hashStore) Exists(ctx context.Context, sid string) (bool, error) {
+	ok, err := hs.Store.Exists(ctx, hs.hash(sid))
+	if err == nil && !ok && hs.legacy(sid) {
+		return hs.Store.Exists(ctx, sid)
+	}
+	return ok, err
+}
+
+// Invalidate invalidate session by plain id, sid is used as is
+// if there is no such session, since it may be hashed id returned by ListUserSessions
+// or plain id of not migrated session
+func (hs *hashStore) Invalidate(ctx context.Context, sid string) error {
+	err := hs.Store.Invalidate(ctx, hs.hash(sid))
+	if !errors.Is(err, session.ErrSessionNotFound) {
+		return err
+	}
+	return hs.Store.Invalidate(ctx, sid)
+}
+
+func (hs *hashStore) Update(ctx context.Context, s *session.Session) (*session.Session, error) {
+	return hs.do(ctx, "Update", s.ID, func(hsid string) (*session.Session, error) {
+		ns := *s
+		ns.ID = hsid
+		return hs.Store.Update(ctx, &ns)
+	})
+}
+
+func (hs *hashStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*session.Session)) (*session.Session, error) {
+	s, err := hs.do(ctx, "Regenerate", sid, func(hsid string) (*session.Session, error) {
+		return hs.Store.Regenerate(ctx, hsid, hs.hash(newSID), grace, modify)
+	})
+	return withID(s, err, newSID)
+}
+
+// InvalidateUserSessions invalidate all sessions bound to uid except exceptSIDs,
+// they may be either plain ids or hashed ids returned by ListUserSessions
+func (hs *hashStore) InvalidateUserSessions(ctx context.Context, uid string, exceptSIDs ...string) error {
+	except := make([]string, 0, 2*len(exceptSIDs))
+	for _, sid := range exceptSIDs {
+		except = append(except, hs.hash(sid), sid)
+	}
+	return hs.Store.InvalidateUserSessions(ctx, uid, except...)
+}
+
+// do call fn with hashed sid, if session isn't found and migration is enabled
+// session stored with plain sid is moved to hashed sid and fn is called again
+func (hs *hashStore) do(ctx context.Context, op, sid string, fn func(hsid string) (*session.Session, error)) (*session.Session, error) {
+	hsid := hs.hash(sid)
+
+	s, err := fn(hsid)
+	if errors.Is(err, session.ErrSessionNotFound) && hs.legacy(sid) {
+		_, merr := hs.Store.Regenerate(ctx, sid, hsid, 0, nil)
+		switch {
+		case merr == nil || errors.Is(merr, session.ErrSessionExists):
+			hs.Logger.V(0).Info("session.sidhash."+op+"() session migrated to hashed id", session.LogKeySID, hsid, session.LogKeyRQID, ctx.Value(hs.CtxReqIDKey))
+			s, err = fn(hsid)
+		case errors.Is(merr, session.ErrSessionExpired) || errors.Is(merr, session.ErrSessionInvalidated):
+			err = merr
+		}
+	}
+
+	return withID(s, err, sid)
+}
+
+// legacy check if sid may be plain id of session stored before hashing,
+// ids kept by the store are rejected, otherwise hashed id read from the store
+// would give access to the session it belongs to
+func (hs *hashStore) legacy(sid string) bool {
+	return hs.Migrate && !strings.HasPrefix(sid, hashPrefix)
+}
+
+func (hs *hashStore) hash(sid string) string {
+	var h hash.Hash
+	if hs.Key != nil {
+		h = hmac.New(sha256.New, hs.Key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(sid))
+	return hashPrefix + hashEnc.EncodeToString(h.Sum(nil))
+}
+
+// withID replace hashed id of s with plain id if there is no error
+func withID(s *session.Session, err error, sid string) (*session.Session, error) {
+	if err != nil {
+		return nil, err
+	}
+	s.ID = sid
+	return s, nil
+}
@@ -0,0 +1,263 @@
+package sidhash_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/asstart/go-session"
+	"github.com/asstart/go-session/memory"
+	"github.com/asstart/go-session/sidhash"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSession(t *testing.T) *session.Session {
+	s, err := session.NewSession()
+	require.Nil(t, err)
+	return &s
+}
+
+func newUserSession(t *testing.T, uid string) *session.Session {
+	s := newTestSession(t)
+	s.WithUserID(uid)
+	return s
+}
+
+func newStores(t *testing.T, opts ...sidhash.Option) (session.Store, session.Store) {
+	inner := memory.NewMemoryStore(context.Background(), logr.Discard(), "key", memory.WithSweepInterval(0))
+	return inner, sidhash.NewStore(inner, logr.Discard(), "key", opts...)
+}
+
+func TestHashedAtRest(t *testing.T) {
+	ctx := context.Background()
+
+	tt := []struct {
+		name string
+		opts []sidhash.Option
+	}{
+		{"sha256", nil},
+		{"hmac", []sidhash.Option{sidhash.WithKey([]byte("key"))}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			inner, store := newStores(t, tc.opts...)
+
+			s := newTestSession(t)
+			created, err := store.Create(ctx, s)
+			require.Nil(t, err)
+			assert.Equal(t, s.ID, created.ID)
+
+			_, err = inner.Load(ctx, s.ID)
+			assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+			loaded, err := store.Load(ctx, s.ID)
+			require.Nil(t, err)
+			assert.Equal(t, s.ID, loaded.ID)
+
+			loaded, err = store.AddAttributes(ctx, s.ID, map[string]interface{}{"k": "v"})
+			require.Nil(t, err)
+			assert.Equal(t, s.ID, loaded.ID)
+
+			updated, err := store.Update(ctx, loaded)
+			require.Nil(t, err)
+			assert.Equal(t, s.ID, updated.ID)
+
+			require.Nil(t, store.Invalidate(ctx, s.ID))
+			_, err = store.Load(ctx, s.ID)
+			assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+		})
+	}
+}
+
+func TestKeyedHashDiffers(t *testing.T) {
+	ctx := context.Background()
+	inner, plain := newStores(t)
+	keyed := sidhash.NewStore(inner, logr.Discard(), "key", sidhash.WithKey([]byte("key")))
+
+	s := newTestSession(t)
+	_, err := plain.Create(ctx, s)
+	require.Nil(t, err)
+
+	_, err = keyed.Load(ctx, s.ID)
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+}
+
+func TestRegenerate(t *testing.T) {
+	ctx := context.Background()
+	_, store := newStores(t)
+
+	s := newTestSession(t)
+	_, err := store.Create(ctx, s)
+	require.Nil(t, err)
+
+	newSID := newTestSession(t).ID
+	regenerated, err := store.Regenerate(ctx, s.ID, newSID, 0, nil)
+	require.Nil(t, err)
+	assert.Equal(t, newSID, regenerated.ID)
+
+	_, err = store.Load(ctx, newSID)
+	assert.Nil(t, err)
+	_, err = store.Load(ctx, s.ID)
+	assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+}
+
+func TestMigration(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		inner, store := newStores(t)
+
+		legacy := newTestSession(t)
+		_, err := inner.Save(ctx, legacy)
+		require.Nil(t, err)
+
+		_, err = store.Load(ctx, legacy.ID)
+		assert.ErrorIs(t, err, session.ErrSessionNotFound)
+	})
+
+	t.Run("load", func(t *testing.T) {
+		inner, store := newStores(t, sidhash.WithMigration())
+
+		legacy := newTestSession(t)
+		legacy.AddAttribute("k", "v")
+		_, err := inner.Save(ctx, legacy)
+		require.Nil(t, err)
+
+		loaded, err := store.Load(ctx, legacy.ID)
+		require.Nil(t, err)
+		assert.Equal(t, legacy.ID, loaded.ID)
+		assert.Equal(t, "v", loaded.Data["k"])
+
+		_, err = inner.Load(ctx, legacy.ID)
+		assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+
+		_, err = store.Load(ctx, legacy.ID)
+		assert.Nil(t, err)
+	})
+
+	t.Run("peek", func(t *testing.T) {
+		inner, store := newStores(t, sidhash.WithMigration())
+
+		legacy := newTestSession(t)
+		_, err := inner.Save(ctx, legacy)
+		require.Nil(t, err)
+
+		peeked, err := store.Peek(ctx, legacy.ID)
+		require.Nil(t, err)
+		assert.Equal(t, legacy.ID, peeked.ID)
+
+		ok, err := store.Exists(ctx, legacy.ID)
+		require.Nil(t, err)
+		assert.True(t, ok)
+
+		// session isn't migrated by Peek and Exists
+		_, err = inner.Peek(ctx, legacy.ID)
+		assert.Nil(t, err)
+	})
+
+	t.Run("add attributes", func(t *testing.T) {
+		inner, store := newStores(t, sidhash.WithMigration())
+
+		legacy := newTestSession(t)
+		_, err := inner.Save(ctx, legacy)
+		require.Nil(t, err)
+
+		s, err := store.AddAttributes(ctx, legacy.ID, map[string]interface{}{"k": "v"})
+		require.Nil(t, err)
+		assert.Equal(t, legacy.ID, s.ID)
+		assert.Equal(t, "v", s.Data["k"])
+	})
+
+	t.Run("invalidated legacy session", func(t *testing.T) {
+		inner, store := newStores(t, sidhash.WithMigration())
+
+		legacy := newTestSession(t)
+		_, err := inner.Save(ctx, legacy)
+		require.Nil(t, err)
+		require.Nil(t, store.Invalidate(ctx, legacy.ID))
+
+		_, err = store.Load(ctx, legacy.ID)
+		assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		_, store := newStores(t, sidhash.WithMigration())
+
+		_, err := store.Load(ctx, newTestSession(t).ID)
+		assert.ErrorIs(t, err, session.ErrSessionNotFound)
+	})
+}
+
+func TestHashedIDIsNotPlainID(t *testing.T) {
+	ctx := context.Background()
+	inner, store := newStores(t, sidhash.WithMigration())
+
+	s := newUserSession(t, "alice")
+	s.AddAttribute("k", "v")
+	_, err := store.Create(ctx, s)
+	require.Nil(t, err)
+
+	sessions, err := store.ListUserSessions(ctx, "alice")
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+	hashed := sessions[0].ID
+
+	_, err = store.Load(ctx, hashed)
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+	_, err = store.Peek(ctx, hashed)
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+	ok, err := store.Exists(ctx, hashed)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	_, err = store.AddAttributes(ctx, hashed, map[string]interface{}{"k": "forged"})
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+	loaded, err := store.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "alice", loaded.UID)
+	assert.Equal(t, "v", loaded.Data["k"])
+
+	_, err = inner.Peek(ctx, hashed)
+	assert.Nil(t, err)
+}
+
+func TestUserSessions(t *testing.T) {
+	ctx := context.Background()
+	_, store := newStores(t)
+
+	uid := newTestSession(t).ID
+	current := newUserSession(t, uid)
+	other := newUserSession(t, uid)
+	for _, s := range []*session.Session{current, other} {
+		_, err := store.Create(ctx, s)
+		require.Nil(t, err)
+	}
+
+	sessions, err := store.ListUserSessions(ctx, uid)
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	for _, s := range sessions {
+		assert.NotEqual(t, current.ID, s.ID)
+		assert.NotEqual(t, other.ID, s.ID)
+	}
+
+	require.Nil(t, store.InvalidateUserSessions(ctx, uid, current.ID))
+
+	_, err = store.Load(ctx, current.ID)
+	assert.Nil(t, err)
+	_, err = store.Load(ctx, other.ID)
+	assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+
+	sessions, err = store.ListUserSessions(ctx, uid)
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+
+	require.Nil(t, store.Invalidate(ctx, sessions[0].ID))
+	_, err = store.Load(ctx, current.ID)
+	assert.ErrorIs(t, err, session.ErrSessionInvalidated)
+}
@@ -0,0 +1,230 @@
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// SignatureSeparator separates session id and its signature in signed session id
+const SignatureSeparator = "."
+
+var ErrInvalidSignature = errors.New("sessionservice: invalid session id signature")
+
+var macEnc = base64.RawURLEncoding
+
+/*
+Signer sign session ids with HMAC-SHA256, signed id is <sid>.<mac>,
+so forged or guessed ids are rejected without touching the store.
+
+The first key signs ids and all keys verify them, so keys can be rotated
+by putting a new key first and removing the old one once sessions signed with it expire.
+*/
+type Signer struct {
+	keys [][]byte
+}
+
+// NewSigner Create Signer with a key ring, keys should be random and at least 32 bytes long
+func NewSigner(keys ...[]byte) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session.NewSigner() at least one key is required")
+	}
+	for _, k := range keys {
+		if len(k) == 0 {
+			return nil, errors.New("session.NewSigner() empty key")
+		}
+	}
+	return &Signer{keys: keys}, nil
+}
+
+// Sign return signed session id
+func (sg *Signer) Sign(sid string) string {
+	return sid + SignatureSeparator + macEnc.EncodeToString(mac(sg.keys[0], sid))
+}
+
+// Verify check signature of signed session id with all keys in constant time
+// and return session id without signature, ErrInvalidSignature is returned if signature isn't valid
+func (sg *Signer) Verify(signed string) (string, error) {
+	sid, sig, ok := splitSignature(signed)
+	if !ok {
+		return "", ErrInvalidSignature
+	}
+
+	valid := false
+	for _, k := range sg.keys {
+		if hmac.Equal(sig, mac(k, sid)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return "", ErrInvalidSignature
+	}
+
+	return sid, nil
+}
+
+func mac(key []byte, sid string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(sid))
+	return h.Sum(nil)
+}
+
+// splitSignature split signed session id into session id and decoded signature
+func splitSignature(signed string) (string, []byte, bool) {
+	i := strings.LastIndex(signed, SignatureSeparator)
+	if i < 0 {
+		return "", nil, false
+	}
+
+	sig, err := macEnc.DecodeString(signed[i+len(SignatureSeparator):])
+	if err != nil || len(sig) != sha256.Size {
+		return "", nil, false
+	}
+
+	return signed[:i], sig, true
+}
+
+// signedStore verify signature of session ids before passing them to Store
+// and sign ids of sessions returned by Store
+type signedStore struct {
+	Store
+	signer *Signer
+}
+
+func (st *signedStore) Save(ctx context.Context, s *Session) (*Session, error) {
+	ns := *s
+	if sid, err := st.signer.Verify(s.ID); err == nil {
+		ns.ID = sid
+	}
+	return st.sign(st.Store.Save(ctx, &ns))
+}
+
+func (st *signedStore) Create(ctx context.Context, s *Session) (*Session, error) {
+	return st.sign(st.Store.Create(ctx, s))
+}
+
+func (st *signedStore) AddAttributes(ctx context.Context, sid string, data map[string]interface{}) (*Session, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return nil, err
+	}
+	return st.sign(st.Store.AddAttributes(ctx, sid, data))
+}
+
+func (st *signedStore) RemoveAttributes(ctx context.Context, sid string, keys ...string) (*Session, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return nil, err
+	}
+	return st.sign(st.Store.RemoveAttributes(ctx, sid, keys...))
+}
+
+func (st *signedStore) IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*Session, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return nil, err
+	}
+	return st.sign(st.Store.IncrementAttribute(ctx, sid, key, delta))
+}
+
+func (st *signedStore) AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*Session, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return nil, err
+	}
+	return st.sign(st.Store.AppendToAttribute(ctx, sid, key, values, maxLen))
+}
+
+func (st *signedStore) Load(ctx context.Context, sid string) (*Session, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return nil, err
+	}
+	return st.sign(st.Store.Load(ctx, sid))
+}
+
+func (st *signedStore) Peek(ctx context.Context, sid string) (*Session, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return nil, err
+	}
+	return st.sign(st.Store.Peek(ctx, sid))
+}
+
+func (st *signedStore) Exists(ctx context.Context, sid string) (bool, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return false, nil
+	}
+	return st.Store.Exists(ctx, sid)
+}
+
+func (st *signedStore) Invalidate(ctx context.Context, sid string) error {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return err
+	}
+	return st.Store.Invalidate(ctx, sid)
+}
+
+func (st *signedStore) Update(ctx context.Context, s *Session) (*Session, error) {
+	sid, err := st.verify(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	ns := *s
+	ns.ID = sid
+	return st.sign(st.Store.Update(ctx, &ns))
+}
+
+func (st *signedStore) Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*Session)) (*Session, error) {
+	sid, err := st.verify(sid)
+	if err != nil {
+		return nil, err
+	}
+	return st.sign(st.Store.Regenerate(ctx, sid, newSID, grace, modify))
+}
+
+func (st *signedStore) ListUserSessions(ctx context.Context, uid string) ([]*Session, error) {
+	sessions, err := st.Store.ListUserSessions(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range sessions {
+		sessions[i], _ = st.sign(s, nil)
+	}
+	return sessions, nil
+}
+
+func (st *signedStore) InvalidateUserSessions(ctx context.Context, uid string, exceptSIDs ...string) error {
+	except := make([]string, 0, len(exceptSIDs))
+	for _, signed := range exceptSIDs {
+		// sessions with forged ids can't be kept anyway
+		if sid, err := st.signer.Verify(signed); err == nil {
+			except = append(except, sid)
+		}
+	}
+	return st.Store.InvalidateUserSessions(ctx, uid, except...)
+}
+
+// verify return ErrSessionNotFound for ids with invalid signature,
+// the same way as for unknown ids
+func (st *signedStore) verify(signed string) (string, error) {
+	sid, err := st.signer.Verify(signed)
+	if err != nil {
+		return "", ErrSessionNotFound
+	}
+	return sid, nil
+}
+
+func (st *signedStore) sign(s *Session, err error) (*Session, error) {
+	if err != nil {
+		return nil, err
+	}
+	s.ID = st.signer.Sign(s.ID)
+	return s, nil
+}
@@ -0,0 +1,173 @@
+package session_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/asstart/go-session"
+	smocks "github.com/asstart/go-session/mocks"
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSID = "A7TF7SGM5WZRW7WMGY7BRJPQOGWGXATZWT35HXPKHRO3DU2J3L4Q"
+
+func newSigner(t *testing.T, keys ...string) *session.Signer {
+	bkeys := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		bkeys = append(bkeys, []byte(k))
+	}
+	sg, err := session.NewSigner(bkeys...)
+	require.Nil(t, err)
+	return sg
+}
+
+func TestNewSignerInvalidKeys(t *testing.T) {
+	_, err := session.NewSigner()
+	assert.NotNil(t, err)
+
+	_, err = session.NewSigner([]byte("key"), nil)
+	assert.NotNil(t, err)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	sg := newSigner(t, "key1")
+
+	signed := sg.Sign(testSID)
+	assert.True(t, strings.HasPrefix(signed, testSID+session.SignatureSeparator))
+
+	sid, err := sg.Verify(signed)
+	assert.Nil(t, err)
+	assert.Equal(t, testSID, sid)
+
+	tampered := []byte(signed)
+	tampered[len(tampered)-2] ^= 1
+
+	tt := []struct {
+		name   string
+		signed string
+	}{
+		{"unsigned", testSID},
+		{"empty", ""},
+		{"tampered signature", string(tampered)},
+		{"other sid", "B" + signed[1:]},
+		{"other key", newSigner(t, "key2").Sign(testSID)},
+		{"short signature", signed[:len(signed)-1]},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := sg.Verify(tc.signed)
+			assert.ErrorIs(t, err, session.ErrInvalidSignature)
+		})
+	}
+}
+
+func TestSignerKeyRotation(t *testing.T) {
+	old := newSigner(t, "old")
+	rotated := newSigner(t, "new", "old")
+
+	sid, err := rotated.Verify(old.Sign(testSID))
+	assert.Nil(t, err)
+	assert.Equal(t, testSID, sid)
+
+	_, err = newSigner(t, "new").Verify(rotated.Sign(testSID))
+	assert.Nil(t, err)
+
+	_, err = newSigner(t, "new").Verify(old.Sign(testSID))
+	assert.ErrorIs(t, err, session.ErrInvalidSignature)
+}
+
+func TestValidateSignedSessionID(t *testing.T) {
+	sg := newSigner(t, "key")
+
+	assert.Nil(t, session.ValidateSessionID(sg.Sign(testSID)))
+	// signature isn't verified without signers
+	assert.Nil(t, session.ValidateSessionID(newSigner(t, "other").Sign(testSID)))
+	assert.Nil(t, session.ValidateSessionID(sg.Sign(testSID), sg))
+	assert.Nil(t, session.ValidateSessionID(sg.Sign(testSID), newSigner(t, "other"), sg))
+
+	assert.ErrorIs(t, session.ValidateSessionID(testSID, sg), session.ErrInvalidSignature)
+	assert.ErrorIs(t, session.ValidateSessionID(newSigner(t, "other").Sign(testSID), sg), session.ErrInvalidSignature)
+	assert.NotNil(t, session.ValidateSessionID(sg.Sign("garbage")))
+	assert.NotNil(t, session.ValidateSessionID(sg.Sign("garbage"), sg))
+}
+
+func TestServiceWithSigner(t *testing.T) {
+	ctx := context.Background()
+	sg := newSigner(t, "key")
+
+	t.Run("forged id isn't passed to store", func(t *testing.T) {
+		store := smocks.NewMockStore(gomock.NewController(t))
+		svc := session.NewService(store, logr.Discard(), "key", session.WithSigner(sg))
+
+		_, err := svc.LoadSession(ctx, testSID)
+		assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+		_, err = svc.AddAttributes(ctx, newSigner(t, "other").Sign(testSID), "k", "v")
+		assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+		_, err = svc.PeekSession(ctx, testSID)
+		assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+		ok, err := svc.SessionExists(ctx, testSID)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("signed id is verified and returned session is signed", func(t *testing.T) {
+		store := smocks.NewMockStore(gomock.NewController(t))
+		svc := session.NewService(store, logr.Discard(), "key", session.WithSigner(sg))
+
+		store.EXPECT().Load(gomock.Any(), testSID).Return(&session.Session{ID: testSID}, nil)
+
+		s, err := svc.LoadSession(ctx, sg.Sign(testSID))
+		assert.Nil(t, err)
+		assert.Equal(t, sg.Sign(testSID), s.ID)
+	})
+
+	t.Run("created session is signed", func(t *testing.T) {
+		store := smocks.NewMockStore(gomock.NewController(t))
+		svc := session.NewService(store, logr.Discard(), "key", session.WithSigner(sg))
+
+		store.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, s *session.Session) (*session.Session, error) {
+			assert.Nil(t, session.ValidateSessionID(s.ID))
+			cp := *s
+			return &cp, nil
+		})
+
+		s, err := svc.CreateAnonymSession(ctx, session.DefaultCookieConf(), session.DefaultSessionConf())
+		assert.Nil(t, err)
+		assert.Nil(t, session.ValidateSessionID(s.ID, sg))
+	})
+
+	t.Run("update passes unsigned id", func(t *testing.T) {
+		store := smocks.NewMockStore(gomock.NewController(t))
+		svc := session.NewService(store, logr.Discard(), "key", session.WithSigner(sg))
+
+		store.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, s *session.Session) (*session.Session, error) {
+			assert.Equal(t, testSID, s.ID)
+			cp := *s
+			return &cp, nil
+		})
+
+		s := &session.Session{ID: sg.Sign(testSID)}
+		updS, err := svc.UpdateSession(ctx, s)
+		assert.Nil(t, err)
+		assert.Equal(t, sg.Sign(testSID), updS.ID)
+		assert.Equal(t, sg.Sign(testSID), s.ID)
+	})
+
+	t.Run("forged except ids are dropped", func(t *testing.T) {
+		store := smocks.NewMockStore(gomock.NewController(t))
+		svc := session.NewService(store, logr.Discard(), "key", session.WithSigner(sg))
+
+		store.EXPECT().InvalidateUserSessions(gomock.Any(), "uid", testSID).Return(nil)
+
+		err := svc.InvalidateUserSessions(ctx, "uid", sg.Sign(testSID), testSID)
+		assert.Nil(t, err)
+	})
+}
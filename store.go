@@ -2,17 +2,65 @@ package session
 
 import (
 	"context"
+	"time"
 )
 
+// Store keeps sessions, every write except touching LastAccessedAt
+// (Load) increments Session.Version.
+//
+// Attribute keys passed to Store methods are attribute paths (see AttributePathSeparator),
+// methods return ErrInvalidAttributeKey if keys aren't valid (see ValidateAttributeKeys).
+//
+// Stores which can't implement a method (e.g. listing sessions kept by clients)
+// return ErrNotSupported
 type Store interface {
-	// Save store session and return its updated copy
+	// Save store session and return its updated copy,
+	// existing session with the same id is replaced keeping its CreatedAt
 	Save(ctx context.Context, s *Session) (*Session, error)
+	// Create store new session and return its updated copy,
+	// return ErrSessionExists if there is a session with the same id
+	Create(ctx context.Context, s *Session) (*Session, error)
 	// Save session attributes and return updated copy of session
 	AddAttributes(ctx context.Context, sid string, data map[string]interface{}) (*Session, error)
 	// Remove session attributes and return updated copy of session
 	RemoveAttributes(ctx context.Context, sid string, keys ...string) (*Session, error)
-	// Load session by its id
+	// IncrementAttribute atomically add delta to numeric attribute key and return updated copy of session,
+	// missing attribute is considered to be 0.
+	// Return ErrAttributeType if the attribute isn't a number
+	IncrementAttribute(ctx context.Context, sid, key string, delta int64) (*Session, error)
+	// AppendToAttribute atomically append values to list attribute key and return updated copy of session,
+	// missing attribute is considered to be empty list. If maxLen > 0 only last maxLen elements are kept.
+	// Return ErrAttributeType if the attribute isn't a list
+	AppendToAttribute(ctx context.Context, sid, key string, values []interface{}, maxLen int) (*Session, error)
+	// Load session by its id and bump its LastAccessedAt,
+	// stores may skip or defer the touch if LastAccessedAt was bumped recently
 	Load(ctx context.Context, sid string) (*Session, error)
+	// Peek load live session by its id without touching it,
+	// so it doesn't extend idle timeout
+	Peek(ctx context.Context, sid string) (*Session, error)
+	// Exists check if session with sid is live without loading or touching it,
+	// it return false and no error if the session is not found, expired or invalidated
+	Exists(ctx context.Context, sid string) (bool, error)
 	// Invalidate session by its id
 	Invalidate(ctx context.Context, sid string) error
+	// Update replace Data, Opts, Anonym, Active, UID and timeouts of the live session with s.ID
+	// and return updated copy, CreatedAt is kept and LastAccessedAt is bumped.
+	// Update is compare-and-swap, it return ErrVersionConflict if stored Version isn't equal to s.Version.
+	// Return ErrSessionNotFound, ErrSessionExpired or ErrSessionInvalidated
+	// if the session isn't live anymore, it's never revived by Update
+	Update(ctx context.Context, s *Session) (*Session, error)
+	// Regenerate move active session to newSID keeping its CreatedAt and return the new copy.
+	// Old session is invalidated, if grace > 0 it stays active
+	// until grace is passed (its AbsTimeout is shortened) to serve in-flight requests.
+	// modify (if not nil) is applied to the new copy before it's stored,
+	// it may change Data, UID, Anonym, Opts and timeouts.
+	// Return ErrSessionExists if there is a session with newSID, old session is left as is.
+	// Only one Regenerate of a session succeeds, the next ones return ErrVersionConflict
+	// while the old session is kept for grace
+	Regenerate(ctx context.Context, sid, newSID string, grace time.Duration, modify func(*Session)) (*Session, error)
+	// ListUserSessions return active not expired sessions bound to uid
+	// sorted by LastAccessedAt, most recent first, sessions aren't touched
+	ListUserSessions(ctx context.Context, uid string) ([]*Session, error)
+	// InvalidateUserSessions invalidate all sessions bound to uid except exceptSIDs
+	InvalidateUserSessions(ctx context.Context, uid string, exceptSIDs ...string) error
 }
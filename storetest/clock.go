@@ -0,0 +1,62 @@
+package storetest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is session.Clock which time changes only with Set and Advance,
+// it's supposed to be injected into stores to test expiry deterministically
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock Create FakeClock showing now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set change current time of the clock
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance move current time of the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testClock is time seen by the suite cases, it's either system time or FakeClock used by the store
+type testClock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type systemTestClock struct{}
+
+func (systemTestClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemTestClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type fakeTestClock struct {
+	*FakeClock
+}
+
+func (c fakeTestClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
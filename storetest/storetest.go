@@ -0,0 +1,838 @@
+// Package storetest provides a conformance test suite for session.Store implementations.
+package storetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessDelay is a pause between calls which should change LastAccessedAt,
+// it's bigger than millisecond precision of BSON datetime
+const accessDelay = 5 * time.Millisecond
+
+const timeDelta = time.Second
+
+// peekIdleTimeout is idle timeout of sessions checked to be not touched by Peek and Exists,
+// the first check is done long before it's passed, so it doesn't fail on slow runs in system time
+const peekIdleTimeout = 500 * time.Millisecond
+
+/*
+RunStoreSuite check that session.Store implementation behaves the way
+sessionService relies on.
+
+newStore is called for every test case and should return ready to use store,
+the store may be shared between calls, every case creates its own sessions.
+Cases wait for sessions to expire in system time, see RunStoreSuiteWithClock.
+*/
+func RunStoreSuite(t *testing.T, newStore func() session.Store) {
+	runStoreSuite(t, systemTestClock{}, newStore)
+}
+
+// RunStoreSuiteWithClock is RunStoreSuite for stores using clock,
+// time is moved with clock.Advance instead of sleeping, so expiry cases are deterministic
+func RunStoreSuiteWithClock(t *testing.T, clock *FakeClock, newStore func() session.Store) {
+	runStoreSuite(t, fakeTestClock{clock}, newStore)
+}
+
+func runStoreSuite(t *testing.T, clk testClock, newStore func() session.Store) {
+	tt := []struct {
+		name string
+		test func(t *testing.T, st session.Store, clk testClock)
+	}{
+		{"Save returns copy with timestamps", testSave},
+		{"Save replaces session with the same id", testSaveReplace},
+		{"Create stores new session", testCreate},
+		{"Create existing session", testCreateExisting},
+		{"Load bumps LastAccessedAt", testLoad},
+		{"Peek doesn't touch session", testPeek},
+		{"Exists reports live sessions", testExists},
+		{"AddAttributes merges data", testAddAttributes},
+		{"RemoveAttributes removes keys", testRemoveAttributes},
+		{"RemoveAttributes on missing keys is no-op", testRemoveMissingAttributes},
+		{"IncrementAttribute adds delta", testIncrementAttribute},
+		{"IncrementAttribute is atomic", testIncrementAttributeConcurrent},
+		{"AppendToAttribute appends and trims", testAppendToAttribute},
+		{"attribute of incompatible type", testAttributeType},
+		{"nested attribute paths", testNestedAttributes},
+		{"invalid attribute keys", testInvalidAttributeKeys},
+		{"attribute types are preserved", testAttributeTypesPreserved},
+		{"Invalidate flips Active", testInvalidate},
+		{"Update replaces session fields", testUpdate},
+		{"Update with stale version", testUpdateVersionConflict},
+		{"writes increment Version", testVersion},
+		{"idle expired session", testIdleExpired},
+		{"absolute expired session", testAbsExpired},
+		{"unknown session", testUnknownSession},
+		{"Regenerate moves session to new id", testRegenerate},
+		{"Regenerate with grace keeps old id valid", testRegenerateGrace},
+		{"Regenerate with grace is done once", testRegenerateGraceOnce},
+		{"Regenerate inactive session", testRegenerateInactive},
+		{"Regenerate applies modify to new session", testRegenerateModify},
+		{"Regenerate to existing session id", testRegenerateExisting},
+		{"ListUserSessions returns active user sessions", testListUserSessions},
+		{"InvalidateUserSessions invalidates all user sessions", testInvalidateUserSessions},
+		{"InvalidateUserSessions keeps excepted sessions", testInvalidateUserSessionsExcept},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newStore(), clk)
+		})
+	}
+}
+
+func newSession(t *testing.T, keyAndValues ...string) *session.Session {
+	s, err := session.NewSession()
+	require.Nil(t, err)
+	for i := 0; i+1 < len(keyAndValues); i += 2 {
+		s.AddAttribute(keyAndValues[i], keyAndValues[i+1])
+	}
+	return &s
+}
+
+func newUserSession(t *testing.T, uid string) *session.Session {
+	s := newSession(t)
+	s.WithUserID(uid)
+	return s
+}
+
+func save(t *testing.T, st session.Store, s *session.Session) *session.Session {
+	saved, err := st.Save(context.Background(), s)
+	require.Nil(t, err)
+	require.NotNil(t, saved)
+	return saved
+}
+
+func testSave(t *testing.T, st session.Store, clk testClock) {
+	s := newSession(t, "k1", "v1")
+	s.WithUserID("uid")
+
+	before := clk.Now()
+	saved := save(t, st, s)
+
+	assert.NotSame(t, s, saved)
+	assert.Equal(t, s.ID, saved.ID)
+	assert.Equal(t, s.UID, saved.UID)
+	assert.Equal(t, s.Anonym, saved.Anonym)
+	assert.Equal(t, s.Active, saved.Active)
+	assert.Equal(t, s.Opts, saved.Opts)
+	assert.Equal(t, s.IdleTimeout, saved.IdleTimeout)
+	assert.Equal(t, s.AbsTimeout, saved.AbsTimeout)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, saved.Data)
+	assert.WithinDuration(t, before, saved.CreatedAt, timeDelta)
+	assert.WithinDuration(t, before, saved.LastAccessedAt, timeDelta)
+
+	saved.Data["k2"] = "v2"
+	loaded, err := st.Load(context.Background(), s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, loaded.Data)
+}
+
+func testSaveReplace(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+
+	s := newSession(t, "k1", "v1")
+	saved := save(t, st, s)
+
+	clk.Sleep(accessDelay)
+
+	s.Data = map[string]interface{}{"k2": "v2"}
+	s.WithUserID("uid")
+	replaced := save(t, st, s)
+	assert.Equal(t, s.ID, replaced.ID)
+	assert.Equal(t, "uid", replaced.UID)
+	assert.Equal(t, map[string]interface{}{"k2": "v2"}, replaced.Data)
+	assert.True(t, saved.CreatedAt.Equal(replaced.CreatedAt))
+	assert.True(t, replaced.LastAccessedAt.After(saved.LastAccessedAt))
+
+	sessions, err := st.ListUserSessions(ctx, "uid")
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, s.ID, sessions[0].ID)
+}
+
+func testCreate(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	s := newSession(t, "k1", "v1")
+
+	before := clk.Now()
+	created, err := st.Create(ctx, s)
+	require.Nil(t, err)
+	assert.NotSame(t, s, created)
+	assert.Equal(t, s.ID, created.ID)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, created.Data)
+	assert.WithinDuration(t, before, created.CreatedAt, timeDelta)
+	assert.WithinDuration(t, before, created.LastAccessedAt, timeDelta)
+
+	loaded, err := st.Load(ctx, s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, created.Data, loaded.Data)
+}
+
+func testCreateExisting(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	s := newSession(t, "k2", "v2")
+	s.ID = saved.ID
+	_, err := st.Create(ctx, s)
+	assert.Equal(t, session.ErrSessionExists, err)
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, loaded.Data)
+}
+
+func testLoad(t *testing.T, st session.Store, clk testClock) {
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	clk.Sleep(accessDelay)
+
+	loaded, err := st.Load(context.Background(), saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, saved.ID, loaded.ID)
+	assert.True(t, loaded.Active)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, loaded.Data)
+	assert.True(t, saved.CreatedAt.Equal(loaded.CreatedAt))
+	assert.True(t, loaded.LastAccessedAt.After(saved.LastAccessedAt))
+}
+
+func testPeek(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	s := newSession(t, "k1", "v1")
+	s.IdleTimeout = peekIdleTimeout
+	saved := save(t, st, s)
+
+	clk.Sleep(peekIdleTimeout / 5)
+
+	peeked, err := st.Peek(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, saved.ID, peeked.ID)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, peeked.Data)
+	assert.Equal(t, saved.Version, peeked.Version)
+	assert.True(t, saved.LastAccessedAt.Equal(peeked.LastAccessedAt))
+
+	clk.Sleep(peekIdleTimeout)
+
+	// idle timeout isn't extended by Peek
+	_, err = st.Peek(ctx, saved.ID)
+	assert.Equal(t, session.ErrSessionExpired, err)
+
+	_, err = st.Peek(ctx, newSession(t).ID)
+	assert.Equal(t, session.ErrSessionNotFound, err)
+
+	invalidated := save(t, st, newSession(t))
+	require.Nil(t, st.Invalidate(ctx, invalidated.ID))
+	_, err = st.Peek(ctx, invalidated.ID)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+}
+
+func testExists(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	s := newSession(t)
+	s.IdleTimeout = peekIdleTimeout
+	saved := save(t, st, s)
+
+	clk.Sleep(peekIdleTimeout / 5)
+
+	ok, err := st.Exists(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	clk.Sleep(peekIdleTimeout)
+
+	// idle timeout isn't extended by Exists
+	ok, err = st.Exists(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = st.Exists(ctx, newSession(t).ID)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	invalidated := save(t, st, newSession(t))
+	require.Nil(t, st.Invalidate(ctx, invalidated.ID))
+	ok, err = st.Exists(ctx, invalidated.ID)
+	require.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func testAddAttributes(t *testing.T, st session.Store, clk testClock) {
+	saved := save(t, st, newSession(t, "k1", "v1", "k2", "v2"))
+
+	clk.Sleep(accessDelay)
+
+	upd, err := st.AddAttributes(context.Background(), saved.ID, map[string]interface{}{"k2": "new", "k3": "v3"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "v1", "k2": "new", "k3": "v3"}, upd.Data)
+	assert.True(t, upd.LastAccessedAt.After(saved.LastAccessedAt))
+
+	loaded, err := st.Load(context.Background(), saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, upd.Data, loaded.Data)
+}
+
+func testRemoveAttributes(t *testing.T, st session.Store, clk testClock) {
+	saved := save(t, st, newSession(t, "k1", "v1", "k2", "v2"))
+
+	upd, err := st.RemoveAttributes(context.Background(), saved.ID, "k1")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k2": "v2"}, upd.Data)
+
+	loaded, err := st.Load(context.Background(), saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, upd.Data, loaded.Data)
+}
+
+func testRemoveMissingAttributes(t *testing.T, st session.Store, clk testClock) {
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	upd, err := st.RemoveAttributes(context.Background(), saved.ID, "missing")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, upd.Data)
+}
+
+func testIncrementAttribute(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	s, err := st.IncrementAttribute(ctx, saved.ID, "n", 2)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), s.Data["n"])
+	assert.Equal(t, "v1", s.Data["k1"])
+	assert.Greater(t, s.Version, saved.Version)
+
+	s, err = st.IncrementAttribute(ctx, saved.ID, "n", -5)
+	require.Nil(t, err)
+	assert.Equal(t, int64(-3), s.Data["n"])
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(-3), loaded.Data["n"])
+}
+
+func testIncrementAttributeConcurrent(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t))
+
+	n := 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := st.IncrementAttribute(ctx, saved.ID, "n", 1)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(n), loaded.Data["n"])
+}
+
+func testAppendToAttribute(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t))
+
+	s, err := st.AppendToAttribute(ctx, saved.ID, "l", []interface{}{"a", "b"}, 0)
+	require.Nil(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, s.Data["l"])
+	assert.Greater(t, s.Version, saved.Version)
+
+	s, err = st.AppendToAttribute(ctx, saved.ID, "l", []interface{}{"c", "d"}, 3)
+	require.Nil(t, err)
+	assert.Equal(t, []interface{}{"b", "c", "d"}, s.Data["l"])
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []interface{}{"b", "c", "d"}, loaded.Data["l"])
+}
+
+func testAttributeType(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	_, err := st.IncrementAttribute(ctx, saved.ID, "k1", 1)
+	assert.Equal(t, session.ErrAttributeType, err)
+
+	_, err = st.AppendToAttribute(ctx, saved.ID, "k1", []interface{}{"a"}, 0)
+	assert.Equal(t, session.ErrAttributeType, err)
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, loaded.Data)
+}
+
+func testNestedAttributes(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	s := newSession(t, "k1", "v1")
+	s.AddAttribute("cart.owner", "uid")
+	saved := save(t, st, s)
+
+	added, err := st.AddAttributes(ctx, saved.ID, map[string]interface{}{
+		"cart.items":    []interface{}{"a"},
+		"cart.meta.tag": "t",
+		"k1.replaced":   "v",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"k1": map[string]interface{}{"replaced": "v"},
+		"cart": map[string]interface{}{
+			"owner": "uid",
+			"items": []interface{}{"a"},
+			"meta":  map[string]interface{}{"tag": "t"},
+		},
+	}, added.Data)
+
+	_, err = st.IncrementAttribute(ctx, saved.ID, "cart.count", 2)
+	require.Nil(t, err)
+	_, err = st.AppendToAttribute(ctx, saved.ID, "cart.items", []interface{}{"b"}, 0)
+	require.Nil(t, err)
+
+	_, err = st.IncrementAttribute(ctx, saved.ID, "cart.items.count", 1)
+	require.Nil(t, err, "list on the path is replaced like any other value")
+
+	removed, err := st.RemoveAttributes(ctx, saved.ID, "cart.meta.tag", "cart.owner", "k1.missing.key", "missing")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"k1": map[string]interface{}{"replaced": "v"},
+		"cart": map[string]interface{}{
+			"items": map[string]interface{}{"count": int64(1)},
+			"meta":  map[string]interface{}{},
+			"count": int64(2),
+		},
+	}, removed.Data)
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, removed.Data, loaded.Data)
+
+	count, ok := loaded.GetInt64("cart.count")
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), count)
+}
+
+func testAttributeTypesPreserved(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	now := clk.Now().UTC().Truncate(time.Millisecond)
+
+	s := newSession(t)
+	s.AddAttribute("int", 1)
+	s.AddAttribute("strings", []string{"a"})
+	s.AddAttribute("nested.times", []time.Time{now})
+	saved := save(t, st, s)
+	assert.Equal(t, s.Data, saved.Data)
+
+	added, err := st.AddAttributes(ctx, saved.ID, map[string]interface{}{
+		"float32":      float32(1.5),
+		"int64s":       []int64{1, 2},
+		"nested.bools": []bool{true},
+		// replaces []string with a map
+		"strings.int": 2,
+	})
+	require.Nil(t, err)
+
+	exp := map[string]interface{}{
+		"int":     1,
+		"float32": float32(1.5),
+		"int64s":  []int64{1, 2},
+		"strings": map[string]interface{}{"int": 2},
+		"nested": map[string]interface{}{
+			"times": []time.Time{now},
+			"bools": []bool{true},
+		},
+	}
+	assert.Equal(t, exp, added.Data)
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, exp, loaded.Data)
+
+	removed, err := st.RemoveAttributes(ctx, saved.ID, "nested.times")
+	require.Nil(t, err)
+	bools, ok := removed.GetBoolSlice("nested.bools")
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true}, bools)
+}
+
+func testInvalidAttributeKeys(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	_, err := st.AddAttributes(ctx, saved.ID, map[string]interface{}{"$where": "v"})
+	assert.ErrorIs(t, err, session.ErrInvalidAttributeKey)
+
+	_, err = st.AddAttributes(ctx, saved.ID, map[string]interface{}{"k1": "v", "k1.k2": "v"})
+	assert.ErrorIs(t, err, session.ErrInvalidAttributeKey)
+
+	_, err = st.RemoveAttributes(ctx, saved.ID, "k1.$")
+	assert.ErrorIs(t, err, session.ErrInvalidAttributeKey)
+
+	_, err = st.IncrementAttribute(ctx, saved.ID, "", 1)
+	assert.ErrorIs(t, err, session.ErrInvalidAttributeKey)
+
+	_, err = st.AppendToAttribute(ctx, saved.ID, "a..b", []interface{}{"v"}, 0)
+	assert.ErrorIs(t, err, session.ErrInvalidAttributeKey)
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, loaded.Data)
+}
+
+func testInvalidate(t *testing.T, st session.Store, clk testClock) {
+	saved := save(t, st, newSession(t))
+
+	err := st.Invalidate(context.Background(), saved.ID)
+	require.Nil(t, err)
+
+	_, err = st.Load(context.Background(), saved.ID)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+
+	_, err = st.AddAttributes(context.Background(), saved.ID, map[string]interface{}{"k": "v"})
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+
+	_, err = st.RemoveAttributes(context.Background(), saved.ID, "k")
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+
+	_, err = st.Update(context.Background(), saved)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+
+	_, err = st.IncrementAttribute(context.Background(), saved.ID, "n", 1)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+
+	_, err = st.AppendToAttribute(context.Background(), saved.ID, "l", []interface{}{"a"}, 0)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+}
+
+func testUpdate(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	clk.Sleep(accessDelay)
+
+	s := *saved
+	s.Data = map[string]interface{}{"k2": "v2"}
+	s.WithUserID("uid")
+	s.WithCookieConf(session.CookieConf{Path: "/app", Domain: "example.com", SameSite: session.SameSiteLaxMode})
+	s.WithSessionConf(session.Conf{IdleTimeout: time.Hour, AbsTimout: 2 * time.Hour})
+	s.CreatedAt = clk.Now().Add(-time.Hour)
+
+	updated, err := st.Update(ctx, &s)
+	require.Nil(t, err)
+	assert.Equal(t, saved.ID, updated.ID)
+	assert.Equal(t, s.Data, updated.Data)
+	assert.Equal(t, "uid", updated.UID)
+	assert.False(t, updated.Anonym)
+	assert.Equal(t, s.Opts, updated.Opts)
+	assert.Equal(t, time.Hour, updated.IdleTimeout)
+	assert.Equal(t, 2*time.Hour, updated.AbsTimeout)
+	assert.True(t, saved.CreatedAt.Equal(updated.CreatedAt))
+	assert.True(t, updated.LastAccessedAt.After(saved.LastAccessedAt))
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, s.Data, loaded.Data)
+	assert.Equal(t, s.Opts, loaded.Opts)
+
+	_, err = st.Update(ctx, newSession(t))
+	assert.Equal(t, session.ErrSessionNotFound, err)
+}
+
+func testUpdateVersionConflict(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	first := *saved
+	first.Data = map[string]interface{}{"k1": "first"}
+	updated, err := st.Update(ctx, &first)
+	require.Nil(t, err)
+	assert.Greater(t, updated.Version, saved.Version)
+
+	second := *saved
+	second.Data = map[string]interface{}{"k1": "second"}
+	_, err = st.Update(ctx, &second)
+	assert.Equal(t, session.ErrVersionConflict, err)
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "first"}, loaded.Data)
+	assert.Equal(t, updated.Version, loaded.Version)
+}
+
+func testVersion(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+
+	saved := save(t, st, newSession(t))
+	assert.Greater(t, saved.Version, int64(0))
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, saved.Version, loaded.Version)
+
+	added, err := st.AddAttributes(ctx, saved.ID, map[string]interface{}{"k": "v"})
+	require.Nil(t, err)
+	assert.Greater(t, added.Version, loaded.Version)
+
+	removed, err := st.RemoveAttributes(ctx, saved.ID, "k")
+	require.Nil(t, err)
+	assert.Greater(t, removed.Version, added.Version)
+
+	resaved := save(t, st, removed)
+	assert.Greater(t, resaved.Version, removed.Version)
+}
+
+func testIdleExpired(t *testing.T, st session.Store, clk testClock) {
+	s := newSession(t)
+	s.IdleTimeout = 50 * time.Millisecond
+	saved := save(t, st, s)
+
+	clk.Sleep(100 * time.Millisecond)
+
+	_, err := st.Load(context.Background(), saved.ID)
+	assert.Equal(t, session.ErrSessionExpired, err)
+
+	_, err = st.AddAttributes(context.Background(), saved.ID, map[string]interface{}{"k": "v"})
+	assert.Equal(t, session.ErrSessionExpired, err)
+
+	_, err = st.RemoveAttributes(context.Background(), saved.ID, "k")
+	assert.Equal(t, session.ErrSessionExpired, err)
+
+	_, err = st.Regenerate(context.Background(), saved.ID, newSession(t).ID, 0, nil)
+	assert.Equal(t, session.ErrSessionExpired, err)
+
+	_, err = st.Update(context.Background(), saved)
+	assert.Equal(t, session.ErrSessionExpired, err)
+
+	// failed calls above mustn't extend idle timeout
+	_, err = st.Load(context.Background(), saved.ID)
+	assert.Equal(t, session.ErrSessionExpired, err)
+}
+
+func testAbsExpired(t *testing.T, st session.Store, clk testClock) {
+	s := newSession(t)
+	s.AbsTimeout = 50 * time.Millisecond
+	saved := save(t, st, s)
+
+	clk.Sleep(100 * time.Millisecond)
+
+	_, err := st.Load(context.Background(), saved.ID)
+	assert.Equal(t, session.ErrSessionExpired, err)
+}
+
+func testUnknownSession(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	sid := newSession(t).ID
+
+	_, err := st.Load(ctx, sid)
+	assert.Equal(t, session.ErrSessionNotFound, err)
+
+	_, err = st.AddAttributes(ctx, sid, map[string]interface{}{"k": "v"})
+	assert.Equal(t, session.ErrSessionNotFound, err)
+
+	_, err = st.RemoveAttributes(ctx, sid, "k")
+	assert.Equal(t, session.ErrSessionNotFound, err)
+
+	err = st.Invalidate(ctx, sid)
+	assert.Equal(t, session.ErrSessionNotFound, err)
+
+	_, err = st.Regenerate(ctx, sid, newSession(t).ID, 0, nil)
+	assert.Equal(t, session.ErrSessionNotFound, err)
+}
+
+func testRegenerate(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+
+	s := newSession(t, "k1", "v1")
+	s.WithUserID("uid")
+	s.WithCookieConf(session.CookieConf{Path: "/app", SameSite: session.SameSiteLaxMode})
+	s.WithSessionConf(session.Conf{IdleTimeout: time.Hour, AbsTimout: 2 * time.Hour})
+	saved := save(t, st, s)
+
+	clk.Sleep(accessDelay)
+
+	newSID := newSession(t).ID
+	regenerated, err := st.Regenerate(ctx, saved.ID, newSID, 0, nil)
+	require.Nil(t, err)
+	assert.Equal(t, newSID, regenerated.ID)
+	assert.True(t, regenerated.Active)
+	assert.Equal(t, saved.Data, regenerated.Data)
+	assert.Equal(t, saved.UID, regenerated.UID)
+	assert.Equal(t, saved.Anonym, regenerated.Anonym)
+	assert.Equal(t, saved.Opts, regenerated.Opts)
+	assert.Equal(t, saved.IdleTimeout, regenerated.IdleTimeout)
+	assert.Equal(t, saved.AbsTimeout, regenerated.AbsTimeout)
+	assert.True(t, saved.CreatedAt.Equal(regenerated.CreatedAt))
+	assert.True(t, regenerated.LastAccessedAt.After(saved.LastAccessedAt))
+
+	loaded, err := st.Load(ctx, newSID)
+	require.Nil(t, err)
+	assert.Equal(t, saved.Data, loaded.Data)
+
+	_, err = st.Load(ctx, saved.ID)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+}
+
+func testRegenerateGrace(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	grace := time.Minute
+
+	saved := save(t, st, newSession(t))
+
+	_, err := st.Regenerate(ctx, saved.ID, newSession(t).ID, grace, nil)
+	require.Nil(t, err)
+
+	old, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.True(t, old.Active)
+	assert.False(t, old.IsExpiredAt(clk.Now()))
+	assert.WithinDuration(t, clk.Now().Add(grace), old.CreatedAt.Add(old.AbsTimeout), timeDelta)
+}
+
+func testRegenerateGraceOnce(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+
+	saved := save(t, st, newSession(t))
+
+	_, err := st.Regenerate(ctx, saved.ID, newSession(t).ID, time.Minute, nil)
+	require.Nil(t, err)
+
+	newSID := newSession(t).ID
+	_, err = st.Regenerate(ctx, saved.ID, newSID, time.Minute, nil)
+	assert.Equal(t, session.ErrVersionConflict, err)
+
+	_, err = st.Load(ctx, saved.ID)
+	assert.Nil(t, err)
+
+	_, err = st.Load(ctx, newSID)
+	assert.Equal(t, session.ErrSessionNotFound, err)
+}
+
+func testRegenerateInactive(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+
+	saved := save(t, st, newSession(t))
+	require.Nil(t, st.Invalidate(ctx, saved.ID))
+
+	_, err := st.Regenerate(ctx, saved.ID, newSession(t).ID, 0, nil)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+}
+
+func testRegenerateModify(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+
+	saved := save(t, st, newSession(t, "k1", "v1"))
+
+	newSID := newSession(t).ID
+	promoted, err := st.Regenerate(ctx, saved.ID, newSID, 0, func(s *session.Session) {
+		s.WithUserID("uid")
+		s.AddAttribute("k2", "v2")
+		s.WithSessionConf(session.Conf{IdleTimeout: time.Hour, AbsTimout: 2 * time.Hour})
+	})
+	require.Nil(t, err)
+	assert.Equal(t, newSID, promoted.ID)
+	assert.Equal(t, "uid", promoted.UID)
+	assert.False(t, promoted.Anonym)
+	assert.Equal(t, time.Hour, promoted.IdleTimeout)
+	assert.Equal(t, 2*time.Hour, promoted.AbsTimeout)
+	assert.Equal(t, map[string]interface{}{"k1": "v1", "k2": "v2"}, promoted.Data)
+	assert.True(t, saved.CreatedAt.Equal(promoted.CreatedAt))
+
+	loaded, err := st.Load(ctx, newSID)
+	require.Nil(t, err)
+	assert.Equal(t, promoted.UID, loaded.UID)
+	assert.Equal(t, promoted.Data, loaded.Data)
+
+	_, err = st.Load(ctx, saved.ID)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+}
+
+func testRegenerateExisting(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	saved := save(t, st, newSession(t, "k1", "v1"))
+	other := save(t, st, newSession(t, "k2", "v2"))
+
+	_, err := st.Regenerate(ctx, saved.ID, other.ID, 0, nil)
+	assert.Equal(t, session.ErrSessionExists, err)
+
+	loaded, err := st.Load(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k1": "v1"}, loaded.Data)
+
+	loaded, err = st.Load(ctx, other.ID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"k2": "v2"}, loaded.Data)
+}
+
+func testListUserSessions(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	uid := newSession(t).ID
+
+	first := save(t, st, newUserSession(t, uid))
+	clk.Sleep(accessDelay)
+	second := save(t, st, newUserSession(t, uid))
+	invalidated := save(t, st, newUserSession(t, uid))
+	require.Nil(t, st.Invalidate(ctx, invalidated.ID))
+	save(t, st, newUserSession(t, newSession(t).ID))
+	save(t, st, newSession(t))
+
+	sessions, err := st.ListUserSessions(ctx, uid)
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, second.ID, sessions[0].ID)
+	assert.Equal(t, first.ID, sessions[1].ID)
+	assert.Equal(t, uid, sessions[0].UID)
+
+	sessions, err = st.ListUserSessions(ctx, newSession(t).ID)
+	require.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func testInvalidateUserSessions(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	uid := newSession(t).ID
+
+	first := save(t, st, newUserSession(t, uid))
+	second := save(t, st, newUserSession(t, uid))
+	another := save(t, st, newUserSession(t, newSession(t).ID))
+
+	require.Nil(t, st.InvalidateUserSessions(ctx, uid))
+
+	for _, sid := range []string{first.ID, second.ID} {
+		_, err := st.Load(ctx, sid)
+		assert.Equal(t, session.ErrSessionInvalidated, err)
+	}
+
+	loaded, err := st.Load(ctx, another.ID)
+	require.Nil(t, err)
+	assert.True(t, loaded.Active)
+
+	sessions, err := st.ListUserSessions(ctx, uid)
+	require.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func testInvalidateUserSessionsExcept(t *testing.T, st session.Store, clk testClock) {
+	ctx := context.Background()
+	uid := newSession(t).ID
+
+	current := save(t, st, newUserSession(t, uid))
+	other := save(t, st, newUserSession(t, uid))
+
+	require.Nil(t, st.InvalidateUserSessions(ctx, uid, current.ID))
+
+	sessions, err := st.ListUserSessions(ctx, uid)
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.ID, sessions[0].ID)
+
+	_, err = st.Load(ctx, other.ID)
+	assert.Equal(t, session.ErrSessionInvalidated, err)
+}
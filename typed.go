@@ -0,0 +1,251 @@
+package session
+
+import (
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+/*
+Get return attribute k (it may be nested attribute path) converted to T,
+it return zero value and false if attribute doesn't exist or can't be converted.
+
+Conversion rules:
+  - value assignable to T is returned as is
+  - numbers are converted to any numeric type if the value survives conversion back unchanged,
+    e.g. int32 or integral float64 decoded by a store can be read as int,
+    but int64 above 2^53 can't be read as float64 and 0.1 can't be read as float32
+  - slices and arrays are converted to slices element by element
+  - maps are converted to maps key by key and value by value
+  - maps and structs are converted to structs with mapstructure (see GetStruct)
+  - RFC 3339 strings are converted to time.Time
+  - pointer T is converted from its element type
+
+All elements of slices and maps have to be converted, otherwise conversion fails.
+*/
+func Get[T any](s *Session, k string) (T, bool) {
+	var zero T
+
+	v, ok := s.GetAttribute(k)
+	if !ok {
+		return zero, false
+	}
+
+	cv, ok := convert(v, reflect.TypeOf(&zero).Elem())
+	if !ok {
+		return zero, false
+	}
+	// nil converted to interface T is nil interface, it can't be asserted to T
+	if !cv.IsValid() || cv.Interface() == nil {
+		return zero, true
+	}
+	return cv.Interface().(T), true
+}
+
+// Set add attribute k to the session, k may be nested attribute path
+func Set[T any](s *Session, k string, v T) {
+	s.AddAttribute(k, v)
+}
+
+// Key is typed attribute key, Default is returned by Get if attribute doesn't exist
+// or can't be converted to T (see session.Get for conversion rules)
+type Key[T any] struct {
+	Name    string
+	Default T
+}
+
+// NewKey return typed attribute key with default value
+func NewKey[T any](name string, def T) Key[T] {
+	return Key[T]{Name: name, Default: def}
+}
+
+// Get return attribute value or k.Default
+func (k Key[T]) Get(s *Session) T {
+	v, ok := Get[T](s, k.Name)
+	if !ok {
+		return k.Default
+	}
+	return v
+}
+
+// Lookup return attribute value and true or zero value and false
+// if attribute doesn't exist or can't be converted
+func (k Key[T]) Lookup(s *Session) (T, bool) {
+	return Get[T](s, k.Name)
+}
+
+// Set add attribute to the session
+func (k Key[T]) Set(s *Session, v T) {
+	Set(s, k.Name, v)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// convert v to type t, see Get for conversion rules
+func convert(v interface{}, t reflect.Type) (reflect.Value, bool) {
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), true
+		default:
+			return reflect.Value{}, false
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(t) {
+		cv := reflect.New(t).Elem()
+		cv.Set(rv)
+		return cv, true
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return convertNumber(rv, t)
+	case reflect.Slice:
+		return convertSlice(rv, t)
+	case reflect.Map:
+		return convertMap(rv, t)
+	case reflect.Struct:
+		return convertStruct(v, rv, t)
+	case reflect.Ptr:
+		ev, ok := convert(v, t.Elem())
+		if !ok {
+			return reflect.Value{}, false
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(ev)
+		return p, true
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+// convertTime convert RFC 3339 string to time.Time, e.g. time encoded by JSON
+func convertTime(rv reflect.Value) (reflect.Value, bool) {
+	if rv.Kind() != reflect.String {
+		return reflect.Value{}, false
+	}
+	tm, err := time.Parse(time.RFC3339Nano, rv.String())
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	return reflect.ValueOf(tm), true
+}
+
+// convertNumber convert number rv to numeric type t if it fits t without loss,
+// i.e. converting the result back gives rv
+func convertNumber(rv reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	cv := reflect.New(t).Elem()
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cv, setInt(cv, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			if cv.CanUint() && !cv.OverflowUint(u) {
+				cv.SetUint(u)
+				return cv, true
+			}
+			return cv, false
+		}
+		return cv, setInt(cv, int64(u))
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if cv.CanFloat() {
+			cv.SetFloat(f)
+			// NaN isn't equal to itself, but it's kept by conversion
+			return cv, cv.Float() == f || f != f
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return cv, false
+		}
+		return cv, setInt(cv, int64(f))
+	default:
+		return cv, false
+	}
+}
+
+// setInt set i to numeric cv if it fits
+func setInt(cv reflect.Value, i int64) bool {
+	switch {
+	case cv.CanInt():
+		if cv.OverflowInt(i) {
+			return false
+		}
+		cv.SetInt(i)
+	case cv.CanUint():
+		if i < 0 || cv.OverflowUint(uint64(i)) {
+			return false
+		}
+		cv.SetUint(uint64(i))
+	case cv.CanFloat():
+		cv.SetFloat(float64(i))
+		f := cv.Float()
+		// float64(math.MaxInt64) is 2^63, which doesn't fit int64
+		if f >= math.MaxInt64 || int64(f) != i {
+			return false
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func convertSlice(rv reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return reflect.Value{}, false
+	}
+
+	cv := reflect.MakeSlice(t, rv.Len(), rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		ev, ok := convert(rv.Index(i).Interface(), t.Elem())
+		if !ok {
+			return reflect.Value{}, false
+		}
+		cv.Index(i).Set(ev)
+	}
+	return cv, true
+}
+
+func convertMap(rv reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	if rv.Kind() != reflect.Map {
+		return reflect.Value{}, false
+	}
+
+	cv := reflect.MakeMapWithSize(t, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		kv, ok := convert(iter.Key().Interface(), t.Key())
+		if !ok {
+			return reflect.Value{}, false
+		}
+		ev, ok := convert(iter.Value().Interface(), t.Elem())
+		if !ok {
+			return reflect.Value{}, false
+		}
+		cv.SetMapIndex(kv, ev)
+	}
+	return cv, true
+}
+
+func convertStruct(v interface{}, rv reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	if t == timeType {
+		return convertTime(rv)
+	}
+	if rv.Kind() != reflect.Map && rv.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	p := reflect.New(t)
+	err := mapstructure.Decode(v, p.Interface())
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	return p.Elem(), true
+}
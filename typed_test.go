@@ -0,0 +1,155 @@
+package session_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/asstart/go-session"
+	"github.com/stretchr/testify/assert"
+)
+
+type typedCase struct {
+	name  string
+	value interface{}
+	get   func(s *session.Session) (interface{}, bool)
+	exp   interface{}
+	expOk bool
+}
+
+func getAs[T any](s *session.Session) (interface{}, bool) {
+	return session.Get[T](s, "k")
+}
+
+func TestGet(t *testing.T) {
+	now := time.Now()
+
+	type item struct {
+		Name  string
+		Count int
+	}
+
+	tt := []typedCase{
+		{"int as int", 1, getAs[int], 1, true},
+		{"int32 as int", int32(1), getAs[int], 1, true},
+		{"int64 as int8", int64(127), getAs[int8], int8(127), true},
+		{"int64 overflows int8", int64(128), getAs[int8], int8(0), false},
+		{"negative int as uint", -1, getAs[uint], uint(0), false},
+		{"uint64 as uint64", uint64(math.MaxUint64), getAs[uint64], uint64(math.MaxUint64), true},
+		{"uint64 overflows int64", uint64(math.MaxUint64), getAs[int64], int64(0), false},
+		{"integral float as int", 3.0, getAs[int], 3, true},
+		{"fractional float as int", 3.5, getAs[int], 0, false},
+		{"int as float64", int32(3), getAs[float64], 3.0, true},
+		{"float32 as float64", float32(1.5), getAs[float64], 1.5, true},
+		{"int64 2^53 as float64", int64(1 << 53), getAs[float64], float64(1 << 53), true},
+		{"int64 above 2^53 as float64", int64(1<<53 + 1), getAs[float64], 0.0, false},
+		{"max int64 as float64", int64(math.MaxInt64), getAs[float64], 0.0, false},
+		{"float64 as float32", 0.5, getAs[float32], float32(0.5), true},
+		{"inexact float64 as float32", 0.1, getAs[float32], float32(0), false},
+		{"float64 overflows float32", math.MaxFloat64, getAs[float32], float32(0), false},
+		{"string as string", "v", getAs[string], "v", true},
+		{"string as int", "1", getAs[int], 0, false},
+		{"int as string", 1, getAs[string], "", false},
+		{"bool as bool", true, getAs[bool], true, true},
+		{"time as time", now, getAs[time.Time], now, true},
+		{"RFC 3339 string as time", "2022-01-02T03:04:05.000000006Z", getAs[time.Time], time.Date(2022, 1, 2, 3, 4, 5, 6, time.UTC), true},
+		{"string as time", "yesterday", getAs[time.Time], time.Time{}, false},
+		{"map as time", map[string]interface{}{}, getAs[time.Time], time.Time{}, false},
+		{"[]int as []int", []int{1, 2}, getAs[[]int], []int{1, 2}, true},
+		{"[]interface{} as []int", []interface{}{int32(1), int64(2), 3.0}, getAs[[]int], []int{1, 2, 3}, true},
+		{"[]interface{} with string as []int", []interface{}{1, "2"}, getAs[[]int], []int(nil), false},
+		{"[]int as []int64", []int{1, 2}, getAs[[]int64], []int64{1, 2}, true},
+		{"array as slice", [2]int{1, 2}, getAs[[]float64], []float64{1, 2}, true},
+		{"[]interface{} as []string", []interface{}{"a", "b"}, getAs[[]string], []string{"a", "b"}, true},
+		{"[]time as []time", []time.Time{now}, getAs[[]time.Time], []time.Time{now}, true},
+		{"map as map", map[string]interface{}{"a": int32(1)}, getAs[map[string]int], map[string]int{"a": 1}, true},
+		{"map with wrong value as map", map[string]interface{}{"a": "b"}, getAs[map[string]int], map[string]int(nil), false},
+		{"map as struct", map[string]interface{}{"Name": "n", "Count": 2}, getAs[item], item{"n", 2}, true},
+		{"struct as struct", item{"n", 2}, getAs[item], item{"n", 2}, true},
+		{"map as struct pointer", map[string]interface{}{"Name": "n"}, getAs[*item], &item{Name: "n"}, true},
+		{"[]interface{} of maps as []struct", []interface{}{map[string]interface{}{"Name": "n"}}, getAs[[]item], []item{{Name: "n"}}, true},
+		{"string as struct", "v", getAs[item], item{}, false},
+		{"anything as interface", "v", getAs[interface{}], "v", true},
+		{"nil as slice", nil, getAs[[]int], []int(nil), true},
+		{"nil as int", nil, getAs[int], 0, false},
+		{"nil as interface", nil, getAs[any], nil, true},
+		{"nil as error", nil, getAs[error], nil, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := session.Session{}
+			s.AddAttribute("k", tc.value)
+
+			v, ok := tc.get(&s)
+			assert.Equal(t, tc.expOk, ok)
+			assert.Equal(t, tc.exp, v)
+		})
+	}
+}
+
+func TestGettersMatchGet(t *testing.T) {
+	s := session.Session{}
+	s.AddAttribute("big", int64(1<<53+1))
+	s.AddAttribute("float", 2.0)
+	s.AddAttribute("list", []interface{}{int32(1), 2.0})
+
+	_, ok := s.GetFloat64("big")
+	assert.False(t, ok)
+
+	i, ok := s.GetInt("float")
+	assert.True(t, ok)
+	assert.Equal(t, 2, i)
+
+	l, ok := s.GetInt64Slice("list")
+	assert.True(t, ok)
+	assert.Equal(t, []int64{1, 2}, l)
+}
+
+func TestGetMissing(t *testing.T) {
+	s := session.Session{}
+
+	v, ok := session.Get[int](&s, "k")
+	assert.False(t, ok)
+	assert.Equal(t, 0, v)
+
+	s.AddAttribute("a.b", int32(1))
+	v, ok = session.Get[int](&s, "a.b")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+}
+
+func TestSet(t *testing.T) {
+	s := session.Session{}
+
+	session.Set(&s, "cart.items", []string{"a"})
+
+	items, ok := session.Get[[]string](&s, "cart.items")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a"}, items)
+}
+
+func TestKey(t *testing.T) {
+	attempts := session.NewKey("login.attempts", 0)
+	s := session.Session{}
+
+	assert.Equal(t, 0, attempts.Get(&s))
+	_, ok := attempts.Lookup(&s)
+	assert.False(t, ok)
+
+	attempts.Set(&s, 2)
+	assert.Equal(t, 2, attempts.Get(&s))
+
+	// value decoded by a store as another numeric type
+	s.AddAttribute("login.attempts", int64(3))
+	v, ok := attempts.Lookup(&s)
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+
+	// value which can't be converted
+	s.AddAttribute("login.attempts", "three")
+	assert.Equal(t, 0, attempts.Get(&s))
+
+	theme := session.Key[string]{Name: "theme", Default: "light"}
+	assert.Equal(t, "light", theme.Get(&s))
+}
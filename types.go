@@ -0,0 +1,177 @@
+package session
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stores which don't keep Go values as is (e.g. MongoDB) lose attribute types,
+// e.g. []string is read back as []interface{} and int as int32.
+// Such stores save names of registered types of attributes next to them
+// and restore attribute values with RestoreAttributeTypes.
+
+var typeRegistry = struct {
+	sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}{
+	byName: make(map[string]reflect.Type),
+	byType: make(map[reflect.Type]string),
+}
+
+func init() {
+	RegisterType[int]("int")
+	RegisterType[int8]("int8")
+	RegisterType[int16]("int16")
+	RegisterType[int32]("int32")
+	RegisterType[int64]("int64")
+	RegisterType[uint]("uint")
+	RegisterType[uint8]("uint8")
+	RegisterType[uint16]("uint16")
+	RegisterType[uint32]("uint32")
+	RegisterType[uint64]("uint64")
+	RegisterType[float32]("float32")
+	RegisterType[float64]("float64")
+	RegisterType[bool]("bool")
+	RegisterType[string]("string")
+	RegisterType[time.Time]("time")
+	RegisterType[[]int]("[]int")
+	RegisterType[[]int32]("[]int32")
+	RegisterType[[]int64]("[]int64")
+	RegisterType[[]float32]("[]float32")
+	RegisterType[[]float64]("[]float64")
+	RegisterType[[]string]("[]string")
+	RegisterType[[]bool]("[]bool")
+	RegisterType[[]time.Time]("[]time")
+	RegisterType[map[string]string]("map[string]string")
+}
+
+/*
+RegisterType register type T under name, so attribute values of type T
+are read back from stores as T (see RestoreAttributeTypes).
+
+Basic types, time.Time and their slices are registered by default.
+Structs are restored with mapstructure (see Get), so they have to be decodable from map.
+
+Names are saved by stores, they shouldn't be changed while sessions with them exist.
+It panics if name or type is already registered with another type or name.
+It's supposed to be called during initialization like gob.Register.
+*/
+func RegisterType[T any](name string) {
+	var zero T
+	t := reflect.TypeOf(&zero).Elem()
+
+	typeRegistry.Lock()
+	defer typeRegistry.Unlock()
+
+	if rt, ok := typeRegistry.byName[name]; ok && rt != t {
+		panic(fmt.Sprintf("session: type name %q is already registered for %v", name, rt))
+	}
+	if rn, ok := typeRegistry.byType[t]; ok && rn != name {
+		panic(fmt.Sprintf("session: type %v is already registered as %q", t, rn))
+	}
+
+	typeRegistry.byName[name] = t
+	typeRegistry.byType[t] = name
+}
+
+// RegisteredTypes return registered types by their names
+func RegisteredTypes() map[string]reflect.Type {
+	typeRegistry.RLock()
+	defer typeRegistry.RUnlock()
+
+	types := make(map[string]reflect.Type, len(typeRegistry.byName))
+	for n, t := range typeRegistry.byName {
+		types[n] = t
+	}
+	return types
+}
+
+// AttributeTypeName return registered name of type of v
+func AttributeTypeName(v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+
+	typeRegistry.RLock()
+	defer typeRegistry.RUnlock()
+
+	name, ok := typeRegistry.byType[reflect.TypeOf(v)]
+	return name, ok
+}
+
+// AttributeTypes return registered type names of attribute v by key
+// and values of nested map[string]interface{} by their attribute paths,
+// values of not registered types are skipped
+func AttributeTypes(key string, v interface{}) map[string]string {
+	types := map[string]string{}
+	collectTypes(types, key, v)
+	return types
+}
+
+// DataTypes return registered type names of all attributes of data by attribute paths
+func DataTypes(data map[string]interface{}) map[string]string {
+	types := map[string]string{}
+	for k, v := range data {
+		collectTypes(types, k, v)
+	}
+	return types
+}
+
+func collectTypes(types map[string]string, key string, v interface{}) {
+	if name, ok := AttributeTypeName(v); ok {
+		types[key] = name
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, mv := range m {
+			// such keys can't be addressed by attribute path
+			if ValidateAttributeKey(k) != nil || strings.Contains(k, AttributePathSeparator) {
+				continue
+			}
+			collectTypes(types, key+AttributePathSeparator+k, mv)
+		}
+	}
+}
+
+// RestoreAttributeTypes convert attributes of data by paths to registered types by their names,
+// attributes which don't exist or can't be converted are left as is
+func RestoreAttributeTypes(data map[string]interface{}, types map[string]string) {
+	if len(data) == 0 || len(types) == 0 {
+		return
+	}
+
+	// nested attributes are restored before maps containing them
+	paths := make([]string, 0, len(types))
+	for p := range types {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Count(paths[i], AttributePathSeparator) > strings.Count(paths[j], AttributePathSeparator)
+	})
+
+	typeRegistry.RLock()
+	defer typeRegistry.RUnlock()
+
+	for _, p := range paths {
+		t, ok := typeRegistry.byName[types[p]]
+		if !ok {
+			continue
+		}
+
+		segs := SplitAttributePath(p)
+		v, ok := getPath(data, segs)
+		if !ok {
+			continue
+		}
+
+		cv, ok := convert(v, t)
+		if !ok {
+			continue
+		}
+		setPath(data, segs, cv.Interface())
+	}
+}
@@ -0,0 +1,89 @@
+package session_test
+
+import (
+	"testing"
+
+	"github.com/asstart/go-session"
+	"github.com/stretchr/testify/assert"
+)
+
+type registeredItem struct {
+	Name string
+}
+
+type otherItem struct {
+	Name string
+}
+
+func init() {
+	session.RegisterType[registeredItem]("session_test.registeredItem")
+}
+
+func TestRegisterType(t *testing.T) {
+	// the same registration is no-op
+	session.RegisterType[registeredItem]("session_test.registeredItem")
+
+	assert.Panics(t, func() {
+		session.RegisterType[otherItem]("session_test.registeredItem")
+	})
+	assert.Panics(t, func() {
+		session.RegisterType[registeredItem]("session_test.otherName")
+	})
+
+	name, ok := session.AttributeTypeName(registeredItem{})
+	assert.True(t, ok)
+	assert.Equal(t, "session_test.registeredItem", name)
+
+	_, ok = session.AttributeTypeName(otherItem{})
+	assert.False(t, ok)
+
+	_, ok = session.AttributeTypeName(nil)
+	assert.False(t, ok)
+}
+
+func TestDataTypes(t *testing.T) {
+	types := session.DataTypes(map[string]interface{}{
+		"s":     []string{"a"},
+		"item":  registeredItem{},
+		"other": otherItem{},
+		"nested": map[string]interface{}{
+			"i":   1,
+			"a.b": 1,
+		},
+	})
+
+	assert.Equal(t, map[string]string{
+		"s":        "[]string",
+		"item":     "session_test.registeredItem",
+		"nested.i": "int",
+	}, types)
+}
+
+func TestRestoreAttributeTypes(t *testing.T) {
+	data := map[string]interface{}{
+		"s":    []interface{}{"a", "b"},
+		"item": map[string]interface{}{"name": "n"},
+		"nested": map[string]interface{}{
+			"i": int32(1),
+		},
+		"wrong": "v",
+	}
+
+	session.RestoreAttributeTypes(data, map[string]string{
+		"s":        "[]string",
+		"item":     "session_test.registeredItem",
+		"nested.i": "int",
+		"wrong":    "int",
+		"missing":  "int",
+		"unknown":  "unknown",
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"s":    []string{"a", "b"},
+		"item": registeredItem{Name: "n"},
+		"nested": map[string]interface{}{
+			"i": 1,
+		},
+		"wrong": "v",
+	}, data)
+}